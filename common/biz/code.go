@@ -2,51 +2,75 @@ package biz
 
 import (
 	"errors"
+	"fmt"
 	"framework/waError"
 )
 
 const OK = 0
 
 var (
-	Fail                        = waError.NewError(1, errors.New("请求失败"))
-	RequestDataError            = waError.NewError(2, errors.New("请求数据错误"))
-	SqlError                    = waError.NewError(3, errors.New("数据库操作错误"))
-	InvalidUsers                = waError.NewError(4, errors.New("无效用户"))
-	PermissionNotEnough         = waError.NewError(6, errors.New("权限不足"))
-	SmsCodeError                = waError.NewError(7, errors.New("短信验证码错误"))
-	ImgCodeError                = waError.NewError(8, errors.New("图形验证码错误")) // 图形验证码错误
-	SmsSendFailed               = waError.NewError(9, errors.New("短信发送失败"))
-	ServerMaintenance           = waError.NewError(10, errors.New("服务器维护"))
-	NotEnoughGold               = waError.NewError(11, errors.New("钻石不足"))
-	UserDataLocked              = waError.NewError(12, errors.New("用户数据被锁定"))
-	NotEnoughScore              = waError.NewError(13, errors.New("积分不足"))
-	AccountOrPasswordError      = waError.NewError(101, errors.New("账号或密码错误"))
-	GetHallServersFail          = waError.NewError(102, errors.New("获取大厅服务器失败"))
-	AccountExist                = waError.NewError(103, errors.New("账号已存在"))
-	AccountNotExist             = waError.NewError(104, errors.New("帐号不存在"))
-	NotFindBindPhone            = waError.NewError(105, errors.New("该手机号未绑定"))
-	PhoneAlreadyBind            = waError.NewError(106, errors.New("该手机号已被绑定，无法重复绑定"))
-	NotFindUser                 = waError.NewError(107, errors.New("用户不存在"))
-	TokenInfoError              = waError.NewError(201, errors.New("无效的token"))
-	NotEnoughVipLevel           = waError.NewError(202, errors.New("vip等级不足"))
-	BlockedAccount              = waError.NewError(203, errors.New("帐号已冻结"))
-	AlreadyCreatedUnion         = waError.NewError(204, errors.New("已经创建过牌友圈，无法重复创建"))
-	UnionNotExist               = waError.NewError(205, errors.New("联盟不存在"))
-	UserInRoomDataLocked        = waError.NewError(206, errors.New("用户在房间中，无法操作数据"))
-	NotInUnion                  = waError.NewError(207, errors.New("用户不在联盟中"))
-	AlreadyInUnion              = waError.NewError(208, errors.New("用户已经在联盟中"))
-	InviteIdError               = waError.NewError(209, errors.New("邀请码错误"))
-	NotYourMember               = waError.NewError(210, errors.New("添加的用户不是你的下级成员"))
-	ForbidGiveScore             = waError.NewError(211, errors.New("禁止赠送积分"))
-	ForbidInviteScore           = waError.NewError(212, errors.New("禁止玩家或代理邀请玩家"))
-	CanNotCreateNewHongBao      = waError.NewError(213, errors.New("暂时无法分发新的红包"))
-	CanNotLeaveRoom             = waError.NewError(305, errors.New("正在游戏中无法离开房间"))
-	RoomCountReachLimit         = waError.NewError(301, errors.New("房间数量到达上线"))
-	LeaveRoomGoldNotEnoughLimit = waError.NewError(302, errors.New("金币不足，无法开始游戏"))
-	LeaveRoomGoldExceedLimit    = waError.NewError(303, errors.New("金币超过最大限度，无法开始游戏"))
-	NotInRoom                   = waError.NewError(306, errors.New("不在该房间中"))
-	RoomPlayerCountFull         = waError.NewError(307, errors.New("房间玩家已满"))
-	RoomNotExist                = waError.NewError(308, errors.New("房间不存在"))
-	CanNotEnterNotLocation      = waError.NewError(309, errors.New("无法进入房间，获取定位信息失败"))
-	CanNotEnterTooNear          = waError.NewError(310, errors.New("无法进入房间，与房间中的其他玩家太近"))
+	Fail                        = newError(1, "请求失败")
+	RequestDataError            = newError(2, "请求数据错误")
+	SqlError                    = newError(3, "数据库操作错误")
+	InvalidUsers                = newError(4, "无效用户")
+	PermissionNotEnough         = newError(6, "权限不足")
+	SmsCodeError                = newError(7, "短信验证码错误")
+	ImgCodeError                = newError(8, "图形验证码错误") // 图形验证码错误
+	SmsSendFailed               = newError(9, "短信发送失败")
+	ServerMaintenance           = newError(10, "服务器维护")
+	NotEnoughGold               = newError(11, "钻石不足")
+	UserDataLocked              = newError(12, "用户数据被锁定")
+	NotEnoughScore              = newError(13, "积分不足")
+	RouteNotFound               = newError(14, "路由不存在")
+	ServerNotFound              = newError(15, "没有可用的服务器")
+	RequestTooFrequent          = newError(16, "请求过于频繁")
+	RequestTimeout              = newError(17, "请求超时")
+	AccountOrPasswordError      = newError(101, "账号或密码错误")
+	GetHallServersFail          = newError(102, "获取大厅服务器失败")
+	AccountExist                = newError(103, "账号已存在")
+	AccountNotExist             = newError(104, "帐号不存在")
+	NotFindBindPhone            = newError(105, "该手机号未绑定")
+	PhoneAlreadyBind            = newError(106, "该手机号已被绑定，无法重复绑定")
+	NotFindUser                 = newError(107, "用户不存在")
+	TokenInfoError              = newError(201, "无效的token")
+	NotEnoughVipLevel           = newError(202, "vip等级不足")
+	BlockedAccount              = newError(203, "帐号已冻结")
+	AlreadyCreatedUnion         = newError(204, "已经创建过牌友圈，无法重复创建")
+	UnionNotExist               = newError(205, "联盟不存在")
+	UserInRoomDataLocked        = newError(206, "用户在房间中，无法操作数据")
+	NotInUnion                  = newError(207, "用户不在联盟中")
+	AlreadyInUnion              = newError(208, "用户已经在联盟中")
+	InviteIdError               = newError(209, "邀请码错误")
+	NotYourMember               = newError(210, "添加的用户不是你的下级成员")
+	ForbidGiveScore             = newError(211, "禁止赠送积分")
+	ForbidInviteScore           = newError(212, "禁止玩家或代理邀请玩家")
+	CanNotCreateNewHongBao      = newError(213, "暂时无法分发新的红包")
+	CanNotLeaveRoom             = newError(305, "正在游戏中无法离开房间")
+	RoomCountReachLimit         = newError(301, "房间数量到达上线")
+	LeaveRoomGoldNotEnoughLimit = newError(302, "金币不足，无法开始游戏")
+	LeaveRoomGoldExceedLimit    = newError(303, "金币超过最大限度，无法开始游戏")
+	NotInRoom                   = newError(306, "不在该房间中")
+	RoomPlayerCountFull         = newError(307, "房间玩家已满")
+	RoomNotExist                = newError(308, "房间不存在")
+	CanNotEnterNotLocation      = newError(309, "无法进入房间，获取定位信息失败")
+	CanNotEnterTooNear          = newError(310, "无法进入房间，与房间中的其他玩家太近")
 )
+
+// codes 错误码 -> 错误 用于根据客户端收到的code查找错误信息
+var codes = make(map[int]*waError.Error)
+
+// newError 创建错误并注册到codes 错误码重复时panic
+func newError(code int, msg string) *waError.Error {
+	if _, ok := codes[code]; ok {
+		panic(fmt.Sprintf("biz code %d registered twice", code))
+	}
+	err := waError.NewError(code, errors.New(msg))
+	codes[code] = err
+	return err
+}
+
+// Lookup 根据错误码查找错误
+func Lookup(code int) (*waError.Error, bool) {
+	err, ok := codes[code]
+	return err, ok
+}
@@ -103,3 +103,17 @@ func InitConfig(confFile string) {
 		panic(fmt.Errorf("解析配置文件出错， err :%v \n", err))
 	}
 }
+
+// Load 只读取并解析配置文件 出错时返回错误 不监听修改 用于部署前检查
+func Load(confFile string) (*Config, error) {
+	conf := new(Config)
+	v := viper.New()
+	v.SetConfigFile(confFile)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read %s err:%v", confFile, err)
+	}
+	if err := v.Unmarshal(conf); err != nil {
+		return nil, fmt.Errorf("unmarshal %s err:%v", confFile, err)
+	}
+	return conf, nil
+}
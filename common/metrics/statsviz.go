@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"expvar"
 	"github.com/arl/statsviz"
 	"net/http"
 )
@@ -10,6 +11,7 @@ func Serve(addr string) error {
 	if err := statsviz.Register(mux); err != nil {
 		return err
 	}
+	mux.Handle("/debug/vars", expvar.Handler())
 	if err := http.ListenAndServe(addr, mux); err != nil {
 		return err
 	}
@@ -14,27 +14,23 @@ import (
 // Run 启动程序，启动grpc服务，启动Http服务，加载日志 加载数据库
 func Run(ctx context.Context, serverId string) error {
 	logs.InitLog(config.Conf.AppName)
-	exit := func() {}
-	go func() {
-		c := connector.Default()
-		exit = c.Close
-		c.Run(serverId)
-	}()
+	c := connector.Default()
+	go c.Run(serverId)
 	// 期望有一个优雅启动和停机
 	stop := func() {
-		// other
-		exit()
+		// 停止接收新连接 踢掉所有客户端后关闭nats
+		c.Close()
 		time.Sleep(3 * time.Second)
 		logs.Info("stop app finish")
 	}
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT, syscall.SIGHUP)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT, syscall.SIGHUP)
 	for {
 		select {
 		case <-ctx.Done():
 			stop()
 			return nil
-		case s := <-c:
+		case s := <-ch:
 			switch s {
 			case syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT:
 				stop()
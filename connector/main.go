@@ -9,16 +9,21 @@ import (
 	"flag"
 	"fmt"
 	"framework/game"
+	"log"
 	"os"
 )
 
-var configFile = flag.String("config", "application.yml", "config file")
+var (
+	configFile = flag.String("config", "application.yml", "config file")
+	serverId   = flag.String("serverId", "", "connector server id, default env SERVER_ID")
+)
 
 func main() {
 	// 1. 加载配置
 	flag.Parse()
 	config.InitConfig(*configFile)
 	game.InitConfig("../config")
+	id := connectorId()
 	// 2. 启动监控
 	go func() {
 		err := metrics.Serve(fmt.Sprintf("0.0.0.0:%d", config.Conf.MetricPort))
@@ -27,9 +32,24 @@ func main() {
 		}
 	}()
 	// 3. 启动grpc服务
-	err := app.Run(context.Background(), "connector001")
+	err := app.Run(context.Background(), id)
 	if err != nil {
 		logs.Error("app run err %v", err)
 		os.Exit(-1)
 	}
 }
+
+// connectorId 优先使用命令行参数 其次环境变量SERVER_ID 必须在servers.json中配置过
+func connectorId() string {
+	id := *serverId
+	if id == "" {
+		id = os.Getenv("SERVER_ID")
+	}
+	if id == "" {
+		log.Fatalf("connector server id is required, use -serverId or env SERVER_ID")
+	}
+	if game.Current().GetConnector(id) == nil {
+		log.Fatalf("connector %s not found in servers config", id)
+	}
+	return id
+}
@@ -0,0 +1,502 @@
+package client
+
+import (
+	"common/biz"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"framework/connector/pb"
+	"framework/net"
+	netpb "framework/net/pb"
+	"github.com/gorilla/websocket"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultRequestTimeout 等待响应的默认时间
+	DefaultRequestTimeout = 10 * time.Second
+	handshakeTimeout      = 10 * time.Second
+	handshakeOk           = 200
+	writeWait             = 10 * time.Second
+)
+
+var (
+	ErrClosed            = errors.New("client closed")
+	ErrRequestTimeout    = errors.New("request timeout")
+	ErrHandshakeFailed   = errors.New("handshake failed")
+	ErrUnknownSerializer = errors.New("unknown serializer")
+)
+
+// ResultError 服务端响应的错误码
+type ResultError struct {
+	Code int
+	Msg  string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("code:%d msg:%s", e.Code, e.Msg)
+}
+
+// PushHandler 收到推送时调用 data已经解压 按协商的序列化方式解析
+// 所有推送在同一个goroutine里按到达顺序调用 处理函数里可以发请求
+type PushHandler func(data []byte)
+
+// KickHandler 被服务端踢下线时调用
+type KickHandler func(reason string)
+
+// Options 连接参数 零值使用json 不压缩 不校验来源
+type Options struct {
+	// Serializer json或者protobuf
+	Serializer string
+	// Compress 声明支持压缩 服务端会压缩较大的消息体
+	Compress bool
+	// RequestTimeout 等待响应的时间 默认10秒
+	RequestTimeout time.Duration
+	// TLSConfig 连接wss时使用
+	TLSConfig *tls.Config
+}
+
+// Client connector的客户端 使用与服务端相同的包和消息编解码
+type Client struct {
+	conn       *websocket.Conn
+	opts       Options
+	serializer net.Serializer
+	dict       *net.RouteDict
+	heartbeat  time.Duration
+	config     map[string]any
+	writeLock  sync.Mutex
+	lock       sync.Mutex
+	nextId     uint
+	pending    map[uint]chan *net.Message
+	pushes     map[string]PushHandler
+	// pushQueue 待处理的推送 由dispatchLoop按到达顺序依次处理
+	pushQueue  []*pushItem
+	pushSignal chan struct{}
+	onKick     KickHandler
+	lastActive int64
+	closeChan  chan struct{}
+	closeOnce  sync.Once
+}
+
+// Dial 连接connector并完成握手 addr例如 ws://127.0.0.1:12000
+func Dial(addr string, opts Options) (*Client, error) {
+	serializer, ok := net.GetSerializer(opts.Serializer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSerializer, opts.Serializer)
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = DefaultRequestTimeout
+	}
+	dialer := websocket.Dialer{
+		HandshakeTimeout: handshakeTimeout,
+		TLSClientConfig:  opts.TLSConfig,
+	}
+	conn, _, err := dialer.Dial(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:       conn,
+		opts:       opts,
+		serializer: serializer,
+		dict:       net.NewRouteDict(),
+		pending:    make(map[uint]chan *net.Message),
+		pushes:     make(map[string]PushHandler),
+		lastActive: time.Now().UnixNano(),
+		pushSignal: make(chan struct{}, 1),
+		closeChan:  make(chan struct{}),
+	}
+	if err := c.handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go c.readLoop()
+	go c.dispatchLoop()
+	if c.heartbeat > 0 {
+		go c.heartbeatLoop()
+	}
+	return c, nil
+}
+
+// handshake 握手成功后保存心跳间隔和路由字典 回应HandshakeAck
+func (c *Client) handshake() error {
+	req := net.HandshakeRequest{
+		Sys: net.HandshakeSys{
+			Type:       "go",
+			Serializer: c.serializer.Name(),
+			Compress:   c.opts.Compress,
+		},
+	}
+	data, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+	if err := c.writePacket(net.Handshake, data); err != nil {
+		return err
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	_, frame, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	packets, err := net.DecodeLimit(frame, net.MaxBodyLen)
+	if err != nil {
+		return err
+	}
+	if len(packets) == 0 || packets[0].Type != net.Handshake {
+		return ErrHandshakeFailed
+	}
+	var res net.HandshakeResponse
+	if err := json.Unmarshal(packets[0].Body, &res); err != nil {
+		return err
+	}
+	if res.Code != handshakeOk {
+		return fmt.Errorf("%w: code %d", ErrHandshakeFailed, res.Code)
+	}
+	c.heartbeat = time.Duration(res.Sys.Heartbeat) * time.Second
+	c.dict = dictFrom(res.Sys.Dict)
+	if len(res.Sys.Config) > 0 {
+		if err := json.Unmarshal(res.Sys.Config, &c.config); err != nil {
+			return err
+		}
+	}
+	return c.writePacket(net.HandshakeAck, nil)
+}
+
+// dictFrom 按服务端下发的编码还原路由字典
+func dictFrom(dict map[string]uint16) *net.RouteDict {
+	routes := make([]string, len(dict))
+	for route, code := range dict {
+		if int(code) < 1 || int(code) > len(dict) {
+			// 编码不连续时不使用字典 发送完整路由
+			return nil
+		}
+		routes[code-1] = route
+	}
+	d := net.NewRouteDict()
+	if err := d.Add(routes...); err != nil {
+		return nil
+	}
+	return d
+}
+
+// Serializer 握手协商的序列化方式 用于解析推送
+func (c *Client) Serializer() net.Serializer {
+	return c.serializer
+}
+
+// Config 握手时服务端下发的客户端配置
+func (c *Client) Config() map[string]any {
+	return c.config
+}
+
+// Entry 携带gateway下发的token进入connector
+func (c *Client) Entry(token string) (string, error) {
+	var res pb.EntryResponse
+	if err := c.Call(net.EntryRoute, &pb.EntryRequest{Token: token}, &res); err != nil {
+		return "", err
+	}
+	return res.Uid, nil
+}
+
+// Request 发送请求并等待响应 body为[]byte时原样发送 否则按协商的序列化方式编码
+func (c *Client) Request(route string, body any) (*Result, error) {
+	data, err := c.marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *net.Message, 1)
+	c.lock.Lock()
+	c.nextId++
+	id := c.nextId
+	c.pending[id] = ch
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, id)
+		c.lock.Unlock()
+	}()
+	if err := c.writeMessage(&net.Message{Type: net.Request, ID: id, Route: route, Data: data}); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(c.opts.RequestTimeout)
+	defer timer.Stop()
+	select {
+	case msg := <-ch:
+		return c.decodeResult(msg.Data)
+	case <-timer.C:
+		return nil, ErrRequestTimeout
+	case <-c.closeChan:
+		return nil, ErrClosed
+	}
+}
+
+// Call 发送请求 响应码不是biz.OK时返回*ResultError 成功时把msg解析到reply
+func (c *Client) Call(route string, body any, reply any) error {
+	res, err := c.Request(route, body)
+	if err != nil {
+		return err
+	}
+	if err := res.Err(); err != nil {
+		return err
+	}
+	if reply == nil {
+		return nil
+	}
+	return res.Decode(reply)
+}
+
+// Notify 发送不需要响应的消息
+func (c *Client) Notify(route string, body any) error {
+	data, err := c.marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.writeMessage(&net.Message{Type: net.Notify, Route: route, Data: data})
+}
+
+// OnPush 注册推送处理函数 同一路由重复注册会覆盖
+func (c *Client) OnPush(route string, handler PushHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pushes[route] = handler
+}
+
+// OnKick 注册被踢下线的回调
+func (c *Client) OnKick(handler KickHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onKick = handler
+}
+
+// Done 连接关闭后返回的channel会被关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.closeChan
+}
+
+// Close 可重复调用
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+		c.writeLock.Lock()
+		_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		c.writeLock.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *Client) marshal(body any) ([]byte, error) {
+	switch v := body.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	default:
+		return c.serializer.Marshal(v)
+	}
+}
+
+func (c *Client) readLoop() {
+	defer c.Close()
+	for {
+		_, frame, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+		// 服务端发送的包体最大MaxBodyLen 不受接收客户端数据的MaxPacketSize限制
+		packets, err := net.DecodeLimit(frame, net.MaxBodyLen)
+		if err != nil {
+			return
+		}
+		for _, packet := range packets {
+			if !c.handlePacket(packet) {
+				return
+			}
+		}
+	}
+}
+
+// handlePacket 返回false时关闭连接
+func (c *Client) handlePacket(packet *net.Packet) bool {
+	switch packet.Type {
+	case net.Heartbeat:
+		return true
+	case net.Kick:
+		var body net.KickBody
+		_ = json.Unmarshal(packet.Body, &body)
+		c.lock.Lock()
+		onKick := c.onKick
+		c.lock.Unlock()
+		if onKick != nil {
+			onKick(body.Reason)
+		}
+		return false
+	case net.Data:
+		msg, err := net.MessageDecode(packet.Body, c.dict)
+		if err != nil {
+			return false
+		}
+		if msg.Compressed {
+			if msg.Data, err = net.DecompressLimit(msg.Data, net.MaxBodyLen); err != nil {
+				return false
+			}
+		}
+		c.handleMessage(msg)
+		return true
+	default:
+		return true
+	}
+}
+
+func (c *Client) handleMessage(msg *net.Message) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch msg.Type {
+	case net.Response:
+		if ch, ok := c.pending[msg.ID]; ok {
+			ch <- msg
+			delete(c.pending, msg.ID)
+		}
+	case net.Push:
+		if handler, ok := c.pushes[msg.Route]; ok {
+			// 推送处理函数可能发请求 不能在读goroutine里阻塞 放入队列由dispatchLoop按顺序处理
+			c.pushQueue = append(c.pushQueue, &pushItem{handler: handler, data: msg.Data})
+			select {
+			case c.pushSignal <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+type pushItem struct {
+	handler PushHandler
+	data    []byte
+}
+
+// dispatchLoop 在同一个goroutine里按到达顺序调用推送处理函数 队列不限长度 读goroutine不会被慢的处理函数阻塞
+func (c *Client) dispatchLoop() {
+	for {
+		select {
+		case <-c.pushSignal:
+		case <-c.closeChan:
+			return
+		}
+		for {
+			c.lock.Lock()
+			if len(c.pushQueue) == 0 {
+				c.lock.Unlock()
+				break
+			}
+			item := c.pushQueue[0]
+			c.pushQueue[0] = nil
+			c.pushQueue = c.pushQueue[1:]
+			c.lock.Unlock()
+			item.handler(item.data)
+		}
+	}
+}
+
+// heartbeatLoop 按服务端下发的间隔发送心跳 超过两个间隔没有收到数据时断开
+func (c *Client) heartbeatLoop() {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			last := time.Unix(0, atomic.LoadInt64(&c.lastActive))
+			if time.Since(last) > 2*c.heartbeat {
+				_ = c.Close()
+				return
+			}
+			if err := c.writePacket(net.Heartbeat, nil); err != nil {
+				_ = c.Close()
+				return
+			}
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+func (c *Client) writeMessage(msg *net.Message) error {
+	body, err := net.MessageEncode(msg, c.dict)
+	if err != nil {
+		return err
+	}
+	return c.writePacket(net.Data, body)
+}
+
+func (c *Client) writePacket(t net.PackageType, body []byte) error {
+	buf, err := net.Encode(t, body)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.closeChan:
+		return ErrClosed
+	default:
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.BinaryMessage, buf)
+}
+
+// Result 服务端响应 Msg是按协商的序列化方式编码的数据 出错时是错误信息
+type Result struct {
+	Code       int
+	Msg        []byte
+	serializer net.Serializer
+}
+
+// Err 响应码不是biz.OK时返回*ResultError
+func (r *Result) Err() error {
+	if r.Code == biz.OK {
+		return nil
+	}
+	msg := string(r.Msg)
+	if r.serializer.Name() == net.SerializerJSON {
+		_ = json.Unmarshal(r.Msg, &msg)
+	}
+	return &ResultError{Code: r.Code, Msg: msg}
+}
+
+// Decode 把Msg解析到v
+func (r *Result) Decode(v any) error {
+	return r.serializer.Unmarshal(r.Msg, v)
+}
+
+// decodeResult json响应是{code,msg} protobuf响应是pb.Result
+func (c *Client) decodeResult(data []byte) (*Result, error) {
+	res := &Result{serializer: c.serializer}
+	if c.serializer.Name() == net.SerializerProtobuf {
+		var pbRes netpb.Result
+		if err := c.serializer.Unmarshal(data, &pbRes); err != nil {
+			return nil, err
+		}
+		res.Code = int(pbRes.Code)
+		res.Msg = pbRes.Msg
+		return res, nil
+	}
+	var jsonRes struct {
+		Code int             `json:"code"`
+		Msg  json.RawMessage `json:"msg"`
+	}
+	if err := c.serializer.Unmarshal(data, &jsonRes); err != nil {
+		return nil, err
+	}
+	res.Code = jsonRes.Code
+	res.Msg = jsonRes.Msg
+	return res, nil
+}
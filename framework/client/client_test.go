@@ -0,0 +1,541 @@
+package client
+
+import (
+	"common/biz"
+	"common/config"
+	"common/logs"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"expvar"
+	"framework/connector/pb"
+	"framework/net"
+	"framework/waError"
+	"math/big"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type echoRequest struct {
+	Text string `json:"text"`
+}
+
+// startManager setups在Run之前修改Manager的配置
+func startManager(t *testing.T, setups ...func(m *net.Manager)) (*net.Manager, string) {
+	config.Conf = &config.Config{}
+	logs.InitLog("test")
+	l, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err:%v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	m := net.NewManager()
+	m.ServerId = "connector001"
+	m.ServerType = "connector"
+	m.HeartTime = time.Second
+	m.CompressThreshold = 64
+	m.ClientConfig = func() json.RawMessage {
+		return json.RawMessage(`{"downloadUrl":"http://127.0.0.1/download"}`)
+	}
+	_ = m.RegisterHandler(net.EntryRoute, net.Typed(func(session *net.Session, req *pb.EntryRequest) (any, *waError.Error) {
+		if req.Token == "" {
+			return nil, biz.TokenInfoError
+		}
+		session.SetUid(req.Token)
+		return &pb.EntryResponse{Uid: req.Token}, nil
+	}))
+	_ = m.RegisterHandler("connector.testHandler.echo", net.Typed(func(session *net.Session, req *echoRequest) (any, *waError.Error) {
+		return req, nil
+	}))
+	m.RequestTimeout = 300 * time.Millisecond
+	// 模拟后端 fast在转发返回之前就响应 lost永远不响应
+	m.RemoteHandler = func(session *net.Session, msg *net.Message, route *net.Route) (time.Duration, *waError.Error) {
+		if msg.Route == "hall.testHandler.fast" {
+			data, _ := net.MarshalResult(session.Serializer(), map[string]any{}, nil)
+			_ = m.Response(session.GetCid(), msg.Route, msg.ID, data)
+		}
+		return 0, nil
+	}
+	for _, setup := range setups {
+		setup(m)
+	}
+	go m.Run(addr)
+	for i := 0; i < 50; i++ {
+		if conn, err := stdnet.Dial("tcp", addr); err == nil {
+			_ = conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Cleanup(func() {
+		m.Shutdown(time.Second)
+	})
+	return m, "ws://" + addr
+}
+
+func TestClient(t *testing.T) {
+	m, addr := startManager(t)
+
+	cli, err := Dial(addr, Options{Compress: true})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	if v := cli.Config()["downloadUrl"]; v != "http://127.0.0.1/download" {
+		t.Fatalf("handshake config downloadUrl:%v", v)
+	}
+
+	var resErr *ResultError
+	if err := cli.Call("connector.testHandler.echo", &echoRequest{Text: "hi"}, nil); !errors.As(err, &resErr) || resErr.Code != biz.TokenInfoError.Code {
+		t.Fatalf("request before entry err:%v", err)
+	}
+	uid, err := cli.Entry("10001")
+	if err != nil || uid != "10001" {
+		t.Fatalf("entry uid:%s err:%v", uid, err)
+	}
+
+	// 超过压缩阈值的响应由服务端压缩 客户端自动解压
+	text := strings.Repeat("wa", 100)
+	var echo echoRequest
+	if err := cli.Call("connector.testHandler.echo", &echoRequest{Text: text}, &echo); err != nil || echo.Text != text {
+		t.Fatalf("echo:%+v err:%v", echo, err)
+	}
+
+	pushed := make(chan string, 1)
+	cli.OnPush("connector.testHandler.onPush", func(data []byte) {
+		var req echoRequest
+		_ = cli.Serializer().Unmarshal(data, &req)
+		pushed <- req.Text
+	})
+	payload, _ := net.MarshalPayload(&echoRequest{Text: "push"})
+	if delivered := m.PushToUsers([]string{"10001"}, "connector.testHandler.onPush", payload); len(delivered.Delivered) != 1 {
+		t.Fatalf("push delivered:%v", delivered)
+	}
+	select {
+	case text := <-pushed:
+		if text != "push" {
+			t.Fatalf("push text:%s", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push timeout")
+	}
+
+	if err := cli.Notify("connector.testHandler.echo", &echoRequest{Text: "notify"}); err != nil {
+		t.Fatalf("notify err:%v", err)
+	}
+
+	// 心跳保持连接 超过两个心跳间隔后仍然可以请求
+	time.Sleep(2500 * time.Millisecond)
+	if err := cli.Call("connector.testHandler.echo", &echoRequest{Text: "alive"}, &echo); err != nil || echo.Text != "alive" {
+		t.Fatalf("after heartbeat echo:%+v err:%v", echo, err)
+	}
+}
+
+func TestClientKick(t *testing.T) {
+	_, addr := startManager(t)
+
+	first, err := Dial(addr, Options{Serializer: net.SerializerProtobuf})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	kicked := make(chan string, 1)
+	first.OnKick(func(reason string) {
+		kicked <- reason
+	})
+	if _, err := first.Entry("10001"); err != nil {
+		t.Fatalf("first entry err:%v", err)
+	}
+	// 已经entry的连接不能再换uid entry
+	var resErr *ResultError
+	if _, err := first.Entry("10002"); !errors.As(err, &resErr) || resErr.Code != biz.RequestDataError.Code {
+		t.Fatalf("entry again err:%v", err)
+	}
+
+	second, err := Dial(addr, Options{Serializer: net.SerializerProtobuf})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer second.Close()
+	if _, err := second.Entry("10001"); err != nil {
+		t.Fatalf("second entry err:%v", err)
+	}
+	select {
+	case reason := <-kicked:
+		if reason != net.KickReasonDuplicateLogin {
+			t.Fatalf("kick reason:%s", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("kick timeout")
+	}
+	select {
+	case <-first.Done():
+	case <-time.After(time.Second):
+		t.Fatal("kicked client not closed")
+	}
+}
+
+// TestClientLargePush 服务端发送超过MaxPacketSize的推送 客户端不能断开
+func TestClientLargePush(t *testing.T) {
+	m, addr := startManager(t)
+
+	cli, err := Dial(addr, Options{})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	pushed := make(chan string, 1)
+	cli.OnPush("connector.testHandler.onPush", func(data []byte) {
+		var req echoRequest
+		_ = cli.Serializer().Unmarshal(data, &req)
+		pushed <- req.Text
+	})
+	text := strings.Repeat("w", 80*1024)
+	payload, _ := net.MarshalPayload(&echoRequest{Text: text})
+	m.PushToUsers([]string{"10001"}, "connector.testHandler.onPush", payload)
+	select {
+	case got := <-pushed:
+		if got != text {
+			t.Fatalf("push len:%d", len(got))
+		}
+	case <-cli.Done():
+		t.Fatal("client closed by large push")
+	case <-time.After(time.Second):
+		t.Fatal("push timeout")
+	}
+}
+
+// TestClientPushOrder 推送按服务端发送的顺序处理 处理函数里可以发请求
+func TestClientPushOrder(t *testing.T) {
+	m, addr := startManager(t)
+
+	cli, err := Dial(addr, Options{})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	const n = 200
+	got := make(chan string, n)
+	cli.OnPush("connector.testHandler.onPush", func(data []byte) {
+		var req echoRequest
+		_ = cli.Serializer().Unmarshal(data, &req)
+		if req.Text == "0" {
+			var echo echoRequest
+			if err := cli.Call("connector.testHandler.echo", &echoRequest{Text: "in push"}, &echo); err != nil {
+				t.Errorf("request in push handler err:%v", err)
+			}
+		}
+		got <- req.Text
+	})
+	for i := 0; i < n; i++ {
+		payload, _ := net.MarshalPayload(&echoRequest{Text: strconv.Itoa(i)})
+		m.PushToUsers([]string{"10001"}, "connector.testHandler.onPush", payload)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case text := <-got:
+			if text != strconv.Itoa(i) {
+				t.Fatalf("push %d got %s", i, text)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("push %d timeout", i)
+		}
+	}
+}
+
+// TestClientForward 转发给后端的请求 无论先响应还是不响应 停机时都不会一直等待
+func TestClientForward(t *testing.T) {
+	// 后端路由加入字典后 客户端用编码发送 服务端按字典还原后转发
+	m, addr := startManager(t, func(m *net.Manager) {
+		_ = m.RouteDict.Add("hall.testHandler.fast")
+	})
+
+	cli, err := Dial(addr, Options{RequestTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	if err := cli.Call("hall.testHandler.fast", &echoRequest{}, nil); err != nil {
+		t.Fatalf("fast err:%v", err)
+	}
+	// 后端不响应时 超时后客户端收到超时错误
+	var resErr *ResultError
+	if err := cli.Call("hall.testHandler.lost", &echoRequest{}, nil); !errors.As(err, &resErr) || resErr.Code != biz.RequestTimeout.Code {
+		t.Fatalf("lost request err:%v", err)
+	}
+	start := time.Now()
+	m.Shutdown(3 * time.Second)
+	if cost := time.Since(start); cost > 2*time.Second {
+		t.Fatalf("shutdown waited %v for pending requests", cost)
+	}
+}
+
+// TestClientShutdownKick 停机时还有请求没有响应 等待超时后客户端仍然收到踢下线
+func TestClientShutdownKick(t *testing.T) {
+	m, addr := startManager(t, func(m *net.Manager) {
+		m.RequestTimeout = 10 * time.Second
+	})
+
+	cli, err := Dial(addr, Options{RequestTimeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	kicked := make(chan string, 1)
+	cli.OnKick(func(reason string) {
+		kicked <- reason
+	})
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	go func() {
+		_ = cli.Call("hall.testHandler.lost", &echoRequest{}, nil)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	m.Shutdown(500 * time.Millisecond)
+	select {
+	case reason := <-kicked:
+		if reason != net.KickReasonServerRestart {
+			t.Fatalf("kick reason:%s", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server restart kick not received")
+	}
+}
+
+// TestClientAuthTimeout 只有还连着并且没有entry的连接才会因为超时被踢
+func TestClientAuthTimeout(t *testing.T) {
+	_, addr := startManager(t, func(m *net.Manager) {
+		m.AuthTimeout = 200 * time.Millisecond
+	})
+	authKicks := func() int {
+		v := expvar.Get("connector_kicks").(*expvar.Map).Get(net.KickReasonAuthTimeout)
+		if v == nil {
+			return 0
+		}
+		n, _ := strconv.Atoi(v.String())
+		return n
+	}
+	before := authKicks()
+	for i := 0; i < 3; i++ {
+		cli, err := Dial(addr, Options{})
+		if err != nil {
+			t.Fatalf("dial err:%v", err)
+		}
+		_ = cli.Close()
+	}
+	cli, err := Dial(addr, Options{})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	kicked := make(chan string, 1)
+	cli.OnKick(func(reason string) {
+		kicked <- reason
+	})
+	select {
+	case reason := <-kicked:
+		if reason != net.KickReasonAuthTimeout {
+			t.Fatalf("kick reason:%s", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("auth timeout kick not received")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := authKicks() - before; n != 1 {
+		t.Fatalf("auth timeout kicks:%d", n)
+	}
+}
+
+// TestClientMetricRoutes 未认证、未注册或者不在字典中的后端路由不按路由统计
+func TestClientMetricRoutes(t *testing.T) {
+	_, addr := startManager(t, func(m *net.Manager) {
+		_ = m.RouteDict.Add("hall.testHandler.fast")
+	})
+
+	cli, err := Dial(addr, Options{})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	messagesIn := expvar.Get("connector_messages_in").(*expvar.Map)
+	messagesOut := expvar.Get("connector_messages_out").(*expvar.Map)
+	junk := "connector.junkHandler.junk"
+	_ = cli.Call("hall.junkHandler.beforeEntry", &echoRequest{}, nil)
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	_ = cli.Call(junk, &echoRequest{}, nil)
+	// 转发给后端的路由不在字典中时不按路由统计
+	junkRoutes := []string{"hall.junkHandler.beforeEntry", junk}
+	for i := 0; i < 3; i++ {
+		route := "hall.junk.r" + strconv.Itoa(i)
+		if err := cli.Notify(route, &echoRequest{}); err != nil {
+			t.Fatalf("notify err:%v", err)
+		}
+		junkRoutes = append(junkRoutes, route)
+	}
+	if err := cli.Call("hall.testHandler.fast", &echoRequest{}, nil); err != nil {
+		t.Fatalf("fast err:%v", err)
+	}
+	for _, route := range junkRoutes {
+		if messagesIn.Get(route) != nil || messagesOut.Get(route) != nil {
+			t.Fatalf("route %s counted", route)
+		}
+	}
+	if err := cli.Call("connector.testHandler.echo", &echoRequest{Text: "hi"}, nil); err != nil {
+		t.Fatalf("echo err:%v", err)
+	}
+	if messagesIn.Get("connector.testHandler.echo") == nil || messagesOut.Get("connector.testHandler.echo") == nil {
+		t.Fatal("registered route not counted")
+	}
+	if messagesIn.Get("hall.testHandler.fast") == nil {
+		t.Fatal("dict route of backend not counted")
+	}
+}
+
+// TestClientUndeliverablePush payload不能按protobuf编码时 protobuf用户算作无法推送而不是不在线
+func TestClientUndeliverablePush(t *testing.T) {
+	m, addr := startManager(t)
+
+	cli, err := Dial(addr, Options{Serializer: net.SerializerProtobuf})
+	if err != nil {
+		t.Fatalf("dial err:%v", err)
+	}
+	defer cli.Close()
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	payload, _ := net.MarshalPayload(&echoRequest{Text: "push"})
+	uids := []string{"10001", "10002"}
+	res := m.PushToUsers(uids, "connector.testHandler.onPush", payload)
+	if len(res.Delivered) != 0 || len(res.Undeliverable) != 1 || res.Undeliverable[0] != "10001" {
+		t.Fatalf("push result:%+v", res)
+	}
+	if offline := res.Offline(uids); len(offline) != 1 || offline[0] != "10002" {
+		t.Fatalf("offline:%v", offline)
+	}
+	var undeliverable *net.UndeliverableError
+	if err := res.Err("connector.testHandler.onPush"); !errors.As(err, &undeliverable) {
+		t.Fatalf("push err:%v", err)
+	}
+}
+
+// writeCert 生成127.0.0.1的自签名证书 证书先写到临时文件再rename 与证书更新工具的行为一致
+func writeCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "connector"},
+		IPAddresses:  []stdnet.IP{stdnet.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+		// 自签名证书作为自己的CA
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(file string, block *pem.Block) {
+		tmp := file + ".tmp"
+		if err := os.WriteFile(tmp, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(keyFile, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	write(certFile, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// TestClientTLS 证书文件替换后新的握手使用新证书 已建立的连接不受影响
+func TestClientTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	oldCert := writeCert(t, certFile, keyFile, 1)
+	_, addr := startManager(t, func(m *net.Manager) {
+		m.CertFile = certFile
+		m.KeyFile = keyFile
+	})
+	addr = "wss://" + strings.TrimPrefix(addr, "ws://")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(oldCert)
+	var serial atomic.Int64
+	tlsConfig := func() *tls.Config {
+		return &tls.Config{
+			RootCAs: roots,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				serial.Store(cs.PeerCertificates[0].SerialNumber.Int64())
+				return nil
+			},
+		}
+	}
+	cli, err := Dial(addr, Options{TLSConfig: tlsConfig()})
+	if err != nil {
+		t.Fatalf("dial wss err:%v", err)
+	}
+	defer cli.Close()
+	if _, err := cli.Entry("10001"); err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	if serial.Load() != 1 {
+		t.Fatalf("handshake cert serial:%d", serial.Load())
+	}
+
+	roots.AddCert(writeCert(t, certFile, keyFile, 2))
+	deadline := time.Now().Add(2 * time.Second)
+	for serial.Load() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("new cert not used by new handshake")
+		}
+		time.Sleep(50 * time.Millisecond)
+		if c, err := Dial(addr, Options{TLSConfig: tlsConfig()}); err == nil {
+			_ = c.Close()
+		}
+	}
+	var echo echoRequest
+	if err := cli.Call("connector.testHandler.echo", &echoRequest{Text: "old conn"}, &echo); err != nil || echo.Text != "old conn" {
+		t.Fatalf("request on old conn echo:%+v err:%v", echo, err)
+	}
+}
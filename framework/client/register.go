@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"common/biz"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RegisterParams gateway /register 的参数 与user服务的RegisterParams一致
+type RegisterParams struct {
+	Account       string `json:"account"`
+	Password      string `json:"password"`
+	LoginPlatform int32  `json:"loginPlatform"`
+	SmsCode       string `json:"smsCode"`
+}
+
+// RegisterResult 注册成功后gateway下发的token和connector地址
+type RegisterResult struct {
+	Token      string     `json:"token"`
+	ServerInfo ServerInfo `json:"serverInfo"`
+}
+
+type ServerInfo struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// Addr connector的websocket地址
+func (s ServerInfo) Addr(tls bool) string {
+	scheme := "ws"
+	if tls {
+		scheme = "wss"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, s.Host, s.Port)
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Register 调用gateway注册 gatewayUrl例如 http://127.0.0.1:8080
+func Register(gatewayUrl string, params *RegisterParams) (*RegisterResult, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimRight(gatewayUrl, "/") + "/register"
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("register http status:%d", resp.StatusCode)
+	}
+	var res struct {
+		Code int             `json:"code"`
+		Msg  json.RawMessage `json:"msg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	if res.Code != biz.OK {
+		var msg string
+		_ = json.Unmarshal(res.Msg, &msg)
+		return nil, &ResultError{Code: res.Code, Msg: msg}
+	}
+	var result RegisterResult
+	if err := json.Unmarshal(res.Msg, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"framework/game"
+	"framework/net"
+	"io"
+	stdnet "net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Report 收集所有问题 最后一起输出
+type Report struct {
+	problems []string
+}
+
+func (r *Report) Add(file, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if file != "" {
+		msg = file + ": " + msg
+	}
+	r.problems = append(r.problems, msg)
+}
+
+func (r *Report) Len() int {
+	return len(r.problems)
+}
+
+func (r *Report) Print(w io.Writer) {
+	if len(r.problems) == 0 {
+		fmt.Fprintln(w, "config ok")
+		return
+	}
+	for _, v := range r.problems {
+		fmt.Fprintf(w, "- %s\n", v)
+	}
+	fmt.Fprintf(w, "%d problems found\n", len(r.problems))
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// checkServers game.Check之外的检查 serverType、端口、枚举值和证书文件 serverSelect由game.Check检查
+func checkServers(r *Report, conf *game.Config, serverTypes []string) {
+	file := path.Join(*gameDir, "servers.json")
+	known := make(map[string]bool)
+	for _, v := range serverTypes {
+		if v = strings.TrimSpace(v); v != "" {
+			known[v] = true
+		}
+	}
+	servers := conf.ServersConf
+	if len(servers.Connector) == 0 {
+		r.Add(file, "no connector configured")
+	}
+	ports := make(map[string]string)
+	for _, v := range servers.Connector {
+		if v.ServerType != "" && v.ServerType != "connector" {
+			r.Add(file, "connector %s serverType %q should be connector", v.ID, v.ServerType)
+		}
+		if v.ClientPort > 65535 {
+			r.Add(file, "connector %s invalid clientPort %d", v.ID, v.ClientPort)
+		}
+		// 同一个host上的connector不能使用相同端口
+		addr := stdnet.JoinHostPort(v.Host, strconv.Itoa(v.ClientPort))
+		if id, ok := ports[addr]; ok {
+			r.Add(file, "connector %s and %s both listen on %s", id, v.ID, addr)
+		}
+		ports[addr] = v.ID
+		switch net.SlowConsumerPolicy(v.SlowConsumer) {
+		case "", net.SlowConsumerDrop, net.SlowConsumerClose:
+		default:
+			r.Add(file, "connector %s unknown slowConsumer %q", v.ID, v.SlowConsumer)
+		}
+		if (v.CertFile == "") != (v.KeyFile == "") {
+			r.Add(file, "connector %s certFile and keyFile must be set together", v.ID)
+		}
+		for _, f := range []string{v.CertFile, v.KeyFile} {
+			if f == "" {
+				continue
+			}
+			if _, err := os.Stat(f); err != nil {
+				r.Add(file, "connector %s %v", v.ID, err)
+			}
+		}
+		for _, route := range v.Routes {
+			if _, err := net.ParseRoute(route); err != nil {
+				r.Add(file, "connector %s route %q: %v", v.ID, route, err)
+			}
+		}
+		if l := v.RateLimit; l != nil {
+			if l.Rate < 0 || l.Burst < 0 {
+				r.Add(file, "connector %s negative rateLimit", v.ID)
+			}
+			for _, route := range l.Routes {
+				if route.Route == "" || route.Rate < 0 || route.Burst < 0 {
+					r.Add(file, "connector %s invalid rateLimit route %q", v.ID, route.Route)
+				}
+			}
+		}
+	}
+	for _, v := range servers.Servers {
+		if v.ServerType != "" && !known[v.ServerType] {
+			r.Add(file, "server %s unknown serverType %q", v.ID, v.ServerType)
+		}
+	}
+	for _, serverType := range serverTypes {
+		if serverType = strings.TrimSpace(serverType); serverType != "" && len(servers.TypeServer[serverType]) == 0 {
+			r.Add(file, "no server of serverType %s", serverType)
+		}
+	}
+}
+
+// checkApp 检查端口 以及各服务启动时用到的配置项
+func checkApp(r *Report, app *App) {
+	conf := app.Conf
+	if conf.AppName == "" {
+		r.Add(app.File, "appName is required")
+	}
+	if !validPort(conf.MetricPort) {
+		r.Add(app.File, "invalid metricPort %d", conf.MetricPort)
+	}
+	ports := map[string]int{"port": conf.Port, "wsPort": conf.WsPort, "metricPort": conf.MetricPort, "httpPort": conf.HttpPort}
+	if conf.Grpc.Addr != "" {
+		_, port, err := stdnet.SplitHostPort(conf.Grpc.Addr)
+		if p, _ := strconv.Atoi(port); err != nil || !validPort(p) {
+			r.Add(app.File, "invalid grpc addr %q", conf.Grpc.Addr)
+		} else {
+			ports["grpc.addr"] = p
+		}
+	}
+	used := make(map[int]string)
+	for _, key := range []string{"port", "wsPort", "metricPort", "httpPort", "grpc.addr"} {
+		port := ports[key]
+		if port == 0 {
+			continue
+		}
+		if !validPort(port) {
+			r.Add(app.File, "invalid %s %d", key, port)
+			continue
+		}
+		if other, ok := used[port]; ok {
+			r.Add(app.File, "%s and %s both use port %d", other, key, port)
+		}
+		used[port] = key
+	}
+	for name, v := range conf.Domain {
+		if v.Name == "" {
+			r.Add(app.File, "domain %s without name", name)
+		}
+	}
+	for name, v := range conf.Services {
+		if v.ClientPort != 0 && !validPort(v.ClientPort) {
+			r.Add(app.File, "services.%s invalid clientPort %d", name, v.ClientPort)
+		}
+	}
+
+	switch app.Name {
+	case "gateway":
+		if conf.HttpPort == 0 {
+			r.Add(app.File, "httpPort is required")
+		}
+		requireJwt(r, app)
+		if conf.Domain["user"].Name == "" {
+			r.Add(app.File, "domain user is required")
+		}
+		if len(conf.Etcd.Addrs) == 0 {
+			r.Add(app.File, "etcd addrs is required")
+		}
+		if s := conf.Services["connector"]; s.ClientHost == "" || s.ClientPort == 0 {
+			r.Add(app.File, "services.connector clientHost and clientPort are required")
+		}
+	case "connector":
+		requireJwt(r, app)
+	case "user":
+		if conf.Grpc.Addr == "" {
+			r.Add(app.File, "grpc addr is required")
+		}
+		if len(conf.Etcd.Addrs) == 0 {
+			r.Add(app.File, "etcd addrs is required")
+		}
+		if conf.Etcd.Register.Name == "" || conf.Etcd.Register.Addr == "" {
+			r.Add(app.File, "etcd register name and addr are required")
+		}
+		if mongo := conf.Database.MongoConf; mongo.Url == "" || mongo.Db == "" {
+			r.Add(app.File, "db mongo url and db are required")
+		}
+		if redis := conf.Database.RedisConf; redis.Addr == "" && len(redis.ClusterAddrs) == 0 {
+			r.Add(app.File, "db redis addr or clusterAddrs is required")
+		}
+	}
+}
+
+func requireJwt(r *Report, app *App) {
+	if app.Conf.Jwt.Secret == "" {
+		r.Add(app.File, "jwts secret is required")
+	}
+}
+
+// checkApps 服务之间需要一致的配置
+func checkApps(r *Report, apps []*App, gameConf *game.Config) {
+	byName := make(map[string]*App)
+	for _, v := range apps {
+		byName[v.Name] = v
+	}
+	gateway, connector, user := byName["gateway"], byName["connector"], byName["user"]
+	if gateway != nil && connector != nil && gateway.Conf.Jwt.Secret != connector.Conf.Jwt.Secret {
+		r.Add("", "jwts secret differs between %s and %s, tokens from gateway will be rejected", gateway.File, connector.File)
+	}
+	if gateway != nil && user != nil {
+		if name := gateway.Conf.Domain["user"].Name; name != "" && name != user.Conf.Etcd.Register.Name {
+			r.Add("", "%s domain user %q not registered by %s (etcd register name %q)", gateway.File, name, user.File, user.Conf.Etcd.Register.Name)
+		}
+	}
+	if gateway != nil && gameConf != nil {
+		if port := gateway.Conf.Services["connector"].ClientPort; port != 0 {
+			found := false
+			for _, v := range gameConf.ServersConf.Connector {
+				if v.ClientPort == port {
+					found = true
+					break
+				}
+			}
+			if !found {
+				r.Add("", "%s services.connector clientPort %d not used by any connector in servers.json", gateway.File, port)
+			}
+		}
+	}
+}
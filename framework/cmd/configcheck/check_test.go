@@ -0,0 +1,191 @@
+package main
+
+import (
+	"common/config"
+	"framework/game"
+	"strings"
+	"testing"
+)
+
+// assertProblems 每个want都要出现在某一个问题中 并且问题数相同
+func assertProblems(t *testing.T, r *Report, want ...string) {
+	t.Helper()
+	if r.Len() != len(want) {
+		t.Fatalf("problems = %q, want %d", r.problems, len(want))
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range r.problems {
+			if strings.Contains(p, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("problem %q not reported in %q", w, r.problems)
+		}
+	}
+}
+
+func testServersConfig(connectors []*game.ConnectorConfig, servers ...*game.ServersConfig) *game.Config {
+	conf := &game.Config{}
+	conf.ServersConf.Connector = connectors
+	conf.ServersConf.Servers = servers
+	conf.ServersConf.TypeServer = make(map[string][]*game.ServersConfig)
+	for _, v := range servers {
+		conf.ServersConf.TypeServer[v.ServerType] = append(conf.ServersConf.TypeServer[v.ServerType], v)
+	}
+	return conf
+}
+
+func TestCheckServers(t *testing.T) {
+	hall := &game.ServersConfig{ID: "hall-001", ServerType: "hall"}
+	gameServer := &game.ServersConfig{ID: "game-001", ServerType: "game"}
+	tests := []struct {
+		name       string
+		connectors []*game.ConnectorConfig
+		servers    []*game.ServersConfig
+		want       []string
+	}{
+		{
+			name:       "ok",
+			connectors: []*game.ConnectorConfig{{ID: "connector001", ClientPort: 12000, ServerType: "connector", Routes: []string{"hall.userHandler.onPush"}}},
+			servers:    []*game.ServersConfig{hall, gameServer},
+		},
+		{
+			name:    "no connector",
+			servers: []*game.ServersConfig{hall, gameServer},
+			want:    []string{"no connector configured"},
+		},
+		{
+			name: "all problems reported together",
+			connectors: []*game.ConnectorConfig{
+				{
+					ID:           "connector001",
+					ClientPort:   70000,
+					ServerType:   "hall",
+					SlowConsumer: "block",
+					CertFile:     "cert.pem",
+					Routes:       []string{"hall.onPush"},
+					RateLimit: &game.RateLimitConfig{
+						Rate:   -1,
+						Routes: []*game.RouteLimitConfig{{Rate: 1}},
+					},
+				},
+				{ID: "connector002", ClientPort: 70000},
+			},
+			servers: []*game.ServersConfig{hall, {ID: "chat-001", ServerType: "chat"}},
+			want: []string{
+				`connector connector001 serverType "hall" should be connector`,
+				"connector connector001 invalid clientPort 70000",
+				"connector connector002 invalid clientPort 70000",
+				"connector001 and connector002 both listen on :70000",
+				`connector connector001 unknown slowConsumer "block"`,
+				"connector connector001 certFile and keyFile must be set together",
+				"connector connector001 stat cert.pem",
+				`connector connector001 route "hall.onPush"`,
+				"connector connector001 negative rateLimit",
+				`connector connector001 invalid rateLimit route ""`,
+				`server chat-001 unknown serverType "chat"`,
+				"no server of serverType game",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(Report)
+			checkServers(r, testServersConfig(tt.connectors, tt.servers...), []string{"hall", " game", ""})
+			assertProblems(t, r, tt.want...)
+		})
+	}
+}
+
+func TestCheckApp(t *testing.T) {
+	tests := []struct {
+		name string
+		app  *App
+		want []string
+	}{
+		{
+			name: "connector ok",
+			app: &App{Name: "connector", File: "connector.yml", Conf: &config.Config{
+				AppName: "connector", MetricPort: 5854, WsPort: 12000, Jwt: config.JwtConf{Secret: "secret"},
+			}},
+		},
+		{
+			name: "ports",
+			app: &App{Name: "connector", File: "connector.yml", Conf: &config.Config{
+				MetricPort: 12000, WsPort: 12000, HttpPort: 70000,
+				Grpc:     config.GrpcConf{Addr: "127.0.0.1:abc"},
+				Domain:   map[string]config.Domain{"user": {}},
+				Services: map[string]config.ServicesConf{"connector": {ClientPort: -1}},
+			}},
+			want: []string{
+				"appName is required",
+				"wsPort and metricPort both use port 12000",
+				"invalid httpPort 70000",
+				`invalid grpc addr "127.0.0.1:abc"`,
+				"domain user without name",
+				"services.connector invalid clientPort -1",
+				"jwts secret is required",
+			},
+		},
+		{
+			name: "gateway",
+			app:  &App{Name: "gateway", File: "gateway.yml", Conf: &config.Config{AppName: "gateway", MetricPort: 5855}},
+			want: []string{
+				"httpPort is required",
+				"jwts secret is required",
+				"domain user is required",
+				"etcd addrs is required",
+				"services.connector clientHost and clientPort are required",
+			},
+		},
+		{
+			name: "user",
+			app:  &App{Name: "user", File: "user.yml", Conf: &config.Config{AppName: "user", MetricPort: 5856}},
+			want: []string{
+				"grpc addr is required",
+				"etcd addrs is required",
+				"etcd register name and addr are required",
+				"db mongo url and db are required",
+				"db redis addr or clusterAddrs is required",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := new(Report)
+			checkApp(r, tt.app)
+			assertProblems(t, r, tt.want...)
+		})
+	}
+}
+
+func TestCheckApps(t *testing.T) {
+	gateway := &App{Name: "gateway", File: "gateway.yml", Conf: &config.Config{
+		Jwt:      config.JwtConf{Secret: "gateway secret"},
+		Domain:   map[string]config.Domain{"user": {Name: "user/v1"}},
+		Services: map[string]config.ServicesConf{"connector": {ClientHost: "127.0.0.1", ClientPort: 12001}},
+	}}
+	connector := &App{Name: "connector", File: "connector.yml", Conf: &config.Config{
+		Jwt: config.JwtConf{Secret: "connector secret"},
+	}}
+	user := &App{Name: "user", File: "user.yml", Conf: &config.Config{
+		Etcd: config.EtcdConf{Register: config.RegisterServer{Name: "user/v2"}},
+	}}
+	gameConf := testServersConfig([]*game.ConnectorConfig{{ID: "connector001", ClientPort: 12000}})
+
+	r := new(Report)
+	checkApps(r, []*App{gateway, connector, user}, gameConf)
+	assertProblems(t, r,
+		"jwts secret differs between gateway.yml and connector.yml",
+		`gateway.yml domain user "user/v1" not registered by user.yml`,
+		"gateway.yml services.connector clientPort 12001 not used",
+	)
+
+	// 只检查传入的服务
+	r = new(Report)
+	checkApps(r, []*App{connector}, nil)
+	assertProblems(t, r)
+}
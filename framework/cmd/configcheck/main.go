@@ -0,0 +1,75 @@
+// configcheck 部署前校验配置 一次列出所有问题 有问题时退出码为1
+// 参数是各服务的application.yml 服务名取自"服务名=路径"或者文件所在目录名
+//
+//	go run ./cmd/configcheck -game ../config ../gateway/application.yml ../user/application.yml ../connector/application.yml
+package main
+
+import (
+	"common/config"
+	"flag"
+	"fmt"
+	"framework/game"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	gameDir     = flag.String("game", "../config", "dir of gameConfig.json and servers.json")
+	serverTypes = flag.String("serverTypes", "hall,game", "known backend server types, comma separated")
+)
+
+// App 一个服务的application.yml
+type App struct {
+	Name string
+	File string
+	Conf *config.Config
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: configcheck [flags] [service=]application.yml...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	r := new(Report)
+
+	gameConf, problems := game.Check(*gameDir)
+	for _, v := range problems {
+		r.Add("", "%v", v)
+	}
+	if gameConf != nil {
+		checkServers(r, gameConf, strings.Split(*serverTypes, ","))
+	}
+
+	var apps []*App
+	for _, arg := range flag.Args() {
+		app := parseApp(arg)
+		conf, err := config.Load(app.File)
+		if err != nil {
+			r.Add("", "%v", err)
+			continue
+		}
+		app.Conf = conf
+		checkApp(r, app)
+		apps = append(apps, app)
+	}
+	checkApps(r, apps, gameConf)
+
+	r.Print(os.Stdout)
+	if r.Len() > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseApp gateway=conf/gateway.yml 或者 gateway/application.yml
+func parseApp(arg string) *App {
+	if name, file, ok := strings.Cut(arg, "="); ok {
+		return &App{Name: name, File: file}
+	}
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		abs = arg
+	}
+	return &App{Name: filepath.Base(filepath.Dir(abs)), File: arg}
+}
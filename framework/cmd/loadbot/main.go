@@ -0,0 +1,197 @@
+// loadbot 模拟大量玩家压测gateway、connector和后端服务器
+// 每个玩家通过gateway注册拿到token 连接connector并entry 然后按脚本发送请求
+//
+//	go run ./cmd/loadbot -gateway http://127.0.0.1:8080 -n 1000 -ramp 20s -duration 2m -script cmd/loadbot/script.example.json
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"framework/client"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	gatewayUrl = flag.String("gateway", "http://127.0.0.1:8080", "gateway url")
+	connector  = flag.String("connector", "", "connector websocket address, default use serverInfo from gateway")
+	players    = flag.Int("n", 100, "number of simulated players")
+	ramp       = flag.Duration("ramp", 10*time.Second, "time to start all players")
+	duration   = flag.Duration("duration", time.Minute, "test duration after all players started")
+	scriptFile = flag.String("script", "", "route mix script, players stay idle when empty")
+	prefix     = flag.String("prefix", "loadbot", "account prefix")
+	platform   = flag.Int("platform", 2, "login platform passed to register")
+	compress   = flag.Bool("compress", false, "negotiate message compression")
+	useTLS     = flag.Bool("tls", false, "connect connector with wss")
+	insecure   = flag.Bool("insecure", false, "skip tls certificate verification")
+	interval   = flag.Duration("interval", 5*time.Second, "progress report interval")
+	verbose    = flag.Bool("v", false, "print every failure")
+)
+
+func main() {
+	flag.Parse()
+	script, err := loadScript(*scriptFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load script err:%v\n", err)
+		os.Exit(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *ramp+*duration)
+	defer cancel()
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+		<-ch
+		cancel()
+	}()
+
+	stats := NewStats()
+	var wg sync.WaitGroup
+	go progress(ctx, stats)
+	// 在ramp时间内均匀启动所有玩家
+	gap := time.Duration(0)
+	if *players > 0 {
+		gap = *ramp / time.Duration(*players)
+	}
+	for i := 0; i < *players; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p := &player{
+				id:     id,
+				script: script,
+				stats:  stats,
+				rand:   rand.New(rand.NewSource(time.Now().UnixNano() + int64(id))),
+			}
+			p.run(ctx)
+		}(i)
+		select {
+		case <-time.After(gap):
+		case <-ctx.Done():
+		}
+	}
+	wg.Wait()
+	stats.Report(os.Stdout)
+}
+
+func progress(ctx context.Context, stats *Stats) {
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	var last int64
+	for {
+		select {
+		case <-ticker.C:
+			stats.Progress(os.Stdout, &last)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type player struct {
+	id     int
+	script *Script
+	stats  *Stats
+	rand   *rand.Rand
+}
+
+func (p *player) run(ctx context.Context) {
+	res, err := client.Register(*gatewayUrl, &client.RegisterParams{
+		Account:       fmt.Sprintf("%s-%d-%d", *prefix, p.id, time.Now().UnixNano()),
+		Password:      *prefix,
+		LoginPlatform: int32(*platform),
+	})
+	if err != nil {
+		p.fail(failRegister, err)
+		return
+	}
+	addr := *connector
+	if addr == "" {
+		addr = res.ServerInfo.Addr(*useTLS)
+	}
+	opts := client.Options{Compress: *compress}
+	if *useTLS {
+		opts.TLSConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	}
+	cli, err := client.Dial(addr, opts)
+	if err != nil {
+		p.fail(failDial, err)
+		return
+	}
+	defer cli.Close()
+	kicked := false
+	cli.OnKick(func(reason string) {
+		kicked = true
+		p.stats.Kick(reason)
+	})
+	start := time.Now()
+	_, err = cli.Entry(res.Token)
+	p.record("connector.entryHandler.entry", start, err)
+	if err != nil {
+		p.fail(failEntry, err)
+		return
+	}
+	p.stats.Online(1)
+	defer p.stats.Online(-1)
+
+	for {
+		if !p.script.idle() {
+			p.step(cli)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-cli.Done():
+			if !kicked {
+				p.stats.Fail(failClosed)
+			}
+			return
+		case <-time.After(p.script.think(p.rand)):
+		}
+	}
+}
+
+// step 按权重选择一个路由发送
+func (p *player) step(cli *client.Client) {
+	route := p.script.next(p.rand)
+	body := []byte(route.Body)
+	if route.Notify {
+		if err := cli.Notify(route.Route, body); err != nil {
+			p.fail(failRequest, err)
+		}
+		return
+	}
+	start := time.Now()
+	res, err := cli.Request(route.Route, body)
+	if err != nil {
+		p.fail(failRequest, err)
+		return
+	}
+	p.record(route.Route, start, res.Err())
+}
+
+// record 有响应的请求计入耗时 错误码按biz统计
+func (p *player) record(route string, start time.Time, err error) {
+	var resErr *client.ResultError
+	if err == nil {
+		p.stats.Request(route, time.Since(start), 0)
+	} else if errors.As(err, &resErr) {
+		p.stats.Request(route, time.Since(start), resErr.Code)
+	}
+}
+
+func (p *player) fail(stage string, err error) {
+	p.stats.Fail(stage)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "player %d %s err:%v\n", p.id, stage, err)
+	}
+}
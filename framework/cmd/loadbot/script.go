@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Script 每个玩家entry之后按权重随机选择路由发送 两次请求之间间隔Think毫秒
+type Script struct {
+	Think  int         `json:"think"`
+	Routes []*RouteMix `json:"routes"`
+	total  int
+}
+
+// RouteMix 路由和请求体 Notify为true时不等待响应
+type RouteMix struct {
+	Route  string          `json:"route"`
+	Weight int             `json:"weight"`
+	Notify bool            `json:"notify"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// loadScript 不指定脚本时玩家entry之后只发心跳 用于测试connector的连接数
+func loadScript(file string) (*Script, error) {
+	script := new(Script)
+	if file == "" {
+		return script, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, script); err != nil {
+		return nil, fmt.Errorf("parse script %s err:%v", file, err)
+	}
+	for _, v := range script.Routes {
+		if v.Route == "" {
+			return nil, errors.New("script route is empty")
+		}
+		if v.Weight <= 0 {
+			v.Weight = 1
+		}
+		script.total += v.Weight
+	}
+	return script, nil
+}
+
+func (s *Script) idle() bool {
+	return len(s.Routes) == 0
+}
+
+func (s *Script) next(r *rand.Rand) *RouteMix {
+	n := r.Intn(s.total)
+	for _, v := range s.Routes {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return s.Routes[len(s.Routes)-1]
+}
+
+// think 在Think上下浮动50% 避免所有玩家同时发请求
+func (s *Script) think(r *rand.Rand) time.Duration {
+	if s.Think <= 0 {
+		return 0
+	}
+	return time.Duration(s.Think/2+r.Intn(s.Think+1)) * time.Millisecond
+}
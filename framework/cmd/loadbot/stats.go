@@ -0,0 +1,141 @@
+package main
+
+import (
+	"common/biz"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 连接阶段的失败
+const (
+	failRegister = "register"
+	failDial     = "dial"
+	failEntry    = "entry"
+	failRequest  = "request" // 超时或者连接断开 没有拿到响应
+	failKicked   = "kicked"
+	failClosed   = "closed" // 压测结束之前连接被断开
+)
+
+type Stats struct {
+	sync.Mutex
+	start     time.Time
+	online    int64
+	requests  int64
+	latencies map[string][]time.Duration // route -> 请求耗时
+	codes     map[int]int                // 响应错误码 -> 次数
+	failures  map[string]int
+	kicks     map[string]int // 踢下线原因 -> 次数
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		start:     time.Now(),
+		latencies: make(map[string][]time.Duration),
+		codes:     make(map[int]int),
+		failures:  make(map[string]int),
+		kicks:     make(map[string]int),
+	}
+}
+
+func (s *Stats) Online(delta int64) {
+	atomic.AddInt64(&s.online, delta)
+}
+
+func (s *Stats) Request(route string, cost time.Duration, code int) {
+	atomic.AddInt64(&s.requests, 1)
+	s.Lock()
+	defer s.Unlock()
+	s.latencies[route] = append(s.latencies[route], cost)
+	if code != biz.OK {
+		s.codes[code]++
+	}
+}
+
+func (s *Stats) Fail(stage string) {
+	s.Lock()
+	defer s.Unlock()
+	s.failures[stage]++
+}
+
+func (s *Stats) Kick(reason string) {
+	s.Lock()
+	defer s.Unlock()
+	s.failures[failKicked]++
+	s.kicks[reason]++
+}
+
+// Progress 压测过程中定时输出
+func (s *Stats) Progress(w io.Writer, last *int64) {
+	requests := atomic.LoadInt64(&s.requests)
+	fmt.Fprintf(w, "[%6.1fs] online:%d requests:%d (+%d)\n",
+		time.Since(s.start).Seconds(), atomic.LoadInt64(&s.online), requests, requests-*last)
+	*last = requests
+}
+
+// Report 汇总各路由的耗时分位数、错误码和连接失败
+func (s *Stats) Report(w io.Writer) {
+	s.Lock()
+	defer s.Unlock()
+	elapsed := time.Since(s.start)
+	fmt.Fprintf(w, "\nduration:%v requests:%d qps:%.1f\n", elapsed.Round(time.Millisecond),
+		atomic.LoadInt64(&s.requests), float64(atomic.LoadInt64(&s.requests))/elapsed.Seconds())
+
+	fmt.Fprintf(w, "\n%-45s %8s %10s %10s %10s %10s\n", "route", "count", "p50", "p90", "p99", "max")
+	for _, route := range sortedKeys(s.latencies) {
+		costs := s.latencies[route]
+		sort.Slice(costs, func(i, j int) bool { return costs[i] < costs[j] })
+		fmt.Fprintf(w, "%-45s %8d %10v %10v %10v %10v\n", route, len(costs),
+			percentile(costs, 50), percentile(costs, 90), percentile(costs, 99), percentile(costs, 100))
+	}
+
+	if len(s.codes) > 0 {
+		fmt.Fprintf(w, "\n%-6s %8s  %s\n", "code", "count", "msg")
+		codes := make([]int, 0, len(s.codes))
+		for code := range s.codes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			msg := "unknown"
+			if err, ok := biz.Lookup(code); ok {
+				msg = err.Error()
+			}
+			fmt.Fprintf(w, "%-6d %8d  %s\n", code, s.codes[code], msg)
+		}
+	}
+
+	if len(s.failures) > 0 {
+		fmt.Fprintf(w, "\n%-10s %8s\n", "failure", "count")
+		for _, stage := range sortedKeys(s.failures) {
+			fmt.Fprintf(w, "%-10s %8d\n", stage, s.failures[stage])
+		}
+		for _, reason := range sortedKeys(s.kicks) {
+			fmt.Fprintf(w, "  kick %-20s %d\n", reason, s.kicks[reason])
+		}
+	}
+}
+
+// percentile costs已经排好序
+func percentile(costs []time.Duration, p int) time.Duration {
+	if len(costs) == 0 {
+		return 0
+	}
+	i := (len(costs)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return costs[i].Round(time.Microsecond)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1 @@
+protoc --go_out=../pb --go_opt=paths=source_relative  *.proto
\ No newline at end of file
@@ -2,38 +2,163 @@ package connector
 
 import (
 	"common/logs"
+	"encoding/json"
 	"fmt"
 	"framework/game"
 	"framework/net"
+	"framework/remote"
+	"sync"
+	"time"
 )
 
 type Connector struct {
-	isRunning bool
-	wsManager *net.Manager
+	sync.Mutex
+	isRunning       bool
+	serverId        string
+	shutdownTimeout time.Duration
+	serverSelect    game.SelectStrategy
+	wsManager       *net.Manager
+	remoteCli       remote.Client
+	remoteReadChan  chan []byte
 }
 
 func Default() *Connector {
-	return &Connector{}
+	c := &Connector{
+		wsManager:      net.NewManager(),
+		remoteReadChan: make(chan []byte, 1024),
+	}
+	c.registerHandlers()
+	return c
+}
+
+// RegisterHandler 注册connector本地处理的路由
+func (c *Connector) RegisterHandler(route string, handler net.HandlerFunc) {
+	if err := c.wsManager.RegisterHandler(route, handler); err != nil {
+		logs.Fatal("connector register handler err:%v", err)
+	}
+}
+
+// AddRoutes 把hall/game等后端的请求和推送路由加入路由字典 客户端可以用2字节编码代替路由字符串
+// 需要在Run之前调用 握手后字典不能再变化
+func (c *Connector) AddRoutes(routes ...string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.isRunning {
+		logs.Fatal("connector add routes after run")
+	}
+	c.addRoutes(routes)
+}
+
+func (c *Connector) addRoutes(routes []string) {
+	for _, route := range routes {
+		if _, err := net.ParseRoute(route); err != nil {
+			logs.Fatal("connector add route %s err:%v", route, err)
+		}
+	}
+	if err := c.wsManager.RouteDict.Add(routes...); err != nil {
+		logs.Fatal("connector add routes err:%v", err)
+	}
+}
+
+func (c *Connector) registerHandlers() {
+	entry := &entryHandler{}
+	c.RegisterHandler(net.EntryRoute, net.Typed(entry.entry))
 }
 
 func (c *Connector) Run(serverId string) {
-	if !c.isRunning {
-		// 启动websocket和nats
-		c.Serve(serverId)
+	c.Lock()
+	if c.isRunning {
+		c.Unlock()
+		return
 	}
+	c.isRunning = true
+	c.Unlock()
+	// 启动websocket和nats
+	c.Serve(serverId)
 }
 
+// Close 停止接收新连接 等待转发中的请求响应后踢掉所有客户端 最后关闭nats
 func (c *Connector) Close() {
-	if c.isRunning {
-		// 关闭websocket和nats
+	c.Lock()
+	defer c.Unlock()
+	if !c.isRunning {
+		return
+	}
+	c.isRunning = false
+	c.wsManager.Shutdown(c.shutdownTimeout)
+	if c.remoteCli != nil {
+		if err := c.remoteCli.Close(); err != nil {
+			logs.Error("connector close nats err:%v", err)
+		}
 	}
+	logs.Info("connector %s closed", c.serverId)
 }
 
 func (c *Connector) Serve(serverId string) {
-	connectorConfig := game.Conf.GetConnector(serverId)
-	if connectorConfig == nil {
-		logs.Fatal("no connector config found")
+	if !c.setup(serverId) {
+		return
 	}
+	connectorConfig := game.Current().GetConnector(serverId)
 	addr := fmt.Sprintf("%s:%d", connectorConfig.Host, connectorConfig.ClientPort)
 	c.wsManager.Run(addr)
 }
+
+// setup 连接nats并配置websocket 已经Close时返回false
+func (c *Connector) setup(serverId string) bool {
+	c.Lock()
+	defer c.Unlock()
+	if !c.isRunning {
+		return false
+	}
+	conf := game.Current()
+	connectorConfig := conf.GetConnector(serverId)
+	if connectorConfig == nil {
+		logs.Fatal("no connector config found")
+	}
+	c.serverId = serverId
+	c.remoteCli = remote.NewNatsClient(conf.ServersConf.Nats.Url, serverId, c.remoteReadChan)
+	if err := c.remoteCli.Run(); err != nil {
+		logs.Fatal("connector connect nats err:%v", err)
+	}
+	if err := c.remoteCli.HandleRequest(remote.PushSubject(serverId), c.handlePush); err != nil {
+		logs.Fatal("connector subscribe push err:%v", err)
+	}
+	go c.remoteReadChanHandler()
+	c.shutdownTimeout = time.Duration(connectorConfig.ShutdownTimeout) * time.Second
+	c.serverSelect = game.SelectStrategy(connectorConfig.ServerSelect)
+	if c.serverSelect == "" {
+		c.serverSelect = game.SelectHash
+	}
+	c.wsManager.ServerId = serverId
+	c.wsManager.ServerType = connectorConfig.ServerType
+	c.wsManager.HeartTime = time.Duration(connectorConfig.HeartTime) * time.Second
+	c.wsManager.WriteQueueSize = connectorConfig.WriteQueueSize
+	c.wsManager.SlowConsumer = net.SlowConsumerPolicy(connectorConfig.SlowConsumer)
+	c.wsManager.RateLimit = rateLimitConfig(connectorConfig.RateLimit)
+	c.wsManager.CompressThreshold = connectorConfig.CompressThreshold
+	c.wsManager.CertFile = connectorConfig.CertFile
+	c.wsManager.KeyFile = connectorConfig.KeyFile
+	// 配置中的后端路由在开始接收连接之前加入字典
+	c.addRoutes(connectorConfig.Routes)
+	c.wsManager.ClientConfig = func() json.RawMessage {
+		return game.Current().ClientConfigJSON()
+	}
+	c.wsManager.RemoteHandler = c.forward
+	c.wsManager.BindUserHandler = c.kickOtherConnectors
+	return true
+}
+
+func rateLimitConfig(conf *game.RateLimitConfig) *net.RateLimitConfig {
+	if conf == nil {
+		return nil
+	}
+	limit := &net.RateLimitConfig{
+		Conn:          net.Limit{Rate: conf.Rate, Burst: conf.Burst},
+		Routes:        make(map[string]net.Limit, len(conf.Routes)),
+		MaxViolations: conf.MaxViolations,
+	}
+	for _, v := range conf.Routes {
+		limit.Routes[v.Route] = net.Limit{Rate: v.Rate, Burst: v.Burst}
+	}
+	return limit
+}
@@ -0,0 +1,184 @@
+package connector
+
+import (
+	"common/config"
+	"common/jwts"
+	"common/logs"
+	"fmt"
+	"framework/client"
+	"framework/game"
+	"framework/net"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+const testSecret = "connector test secret"
+
+// runNatsServer 启动进程内的nats server 随机端口
+func runNatsServer(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		t.Fatalf("new nats server err:%v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err:%v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*stdnet.TCPAddr).Port
+}
+
+// startConnectors 启动连接同一个nats的两个connector 返回客户端地址
+func startConnectors(t *testing.T) []string {
+	config.Conf = &config.Config{Jwt: config.JwtConf{Secret: testSecret}}
+	logs.InitLog("test")
+	s := runNatsServer(t)
+	ports := []int{freePort(t), freePort(t)}
+	servers := fmt.Sprintf(`{
+  "nats": {"url": %q},
+  "connector": [
+    {"id": "connector001", "host": "127.0.0.1", "clientPort": %d, "serverType": "connector", "heartTime": 3},
+    {"id": "connector002", "host": "127.0.0.1", "clientPort": %d, "serverType": "connector", "heartTime": 3}
+  ],
+  "servers": [{"id": "hall-001", "serverType": "hall"}]
+}`, s.ClientURL(), ports[0], ports[1])
+	dir := t.TempDir()
+	for name, content := range map[string]string{"gameConfig.json": "{}", "servers.json": servers} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	game.InitConfig(dir)
+
+	var addrs []string
+	for i, id := range []string{"connector001", "connector002"} {
+		c := Default()
+		go c.Run(id)
+		t.Cleanup(c.Close)
+		addr := "127.0.0.1:" + strconv.Itoa(ports[i])
+		for j := 0; j < 50; j++ {
+			if conn, err := stdnet.Dial("tcp", addr); err == nil {
+				_ = conn.Close()
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		addrs = append(addrs, "ws://"+addr)
+	}
+	return addrs
+}
+
+func entry(t *testing.T, addr, uid string) (*client.Client, chan string) {
+	t.Helper()
+	cli, kicked, err := dialEntry(addr, uid)
+	if err != nil {
+		t.Fatalf("entry err:%v", err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli, kicked
+}
+
+// dialEntry 连接并用uid的token entry 可以在其他goroutine中调用
+func dialEntry(addr, uid string) (*client.Client, chan string, error) {
+	cli, err := client.Dial(addr, client.Options{})
+	if err != nil {
+		return nil, nil, err
+	}
+	kicked := make(chan string, 1)
+	cli.OnKick(func(reason string) {
+		kicked <- reason
+	})
+	token, err := jwts.GenToken(&jwts.CustomClaims{Uid: uid}, testSecret)
+	if err == nil {
+		_, err = cli.Entry(token)
+	}
+	if err != nil {
+		_ = cli.Close()
+		return nil, nil, err
+	}
+	return cli, kicked, nil
+}
+
+func closed(cli *client.Client) bool {
+	select {
+	case <-cli.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// TestKickOtherConnector 同一uid在两个connector上entry 只保留一个连接
+func TestKickOtherConnector(t *testing.T) {
+	addrs := startConnectors(t)
+
+	// 先后entry 踢掉先entry的连接
+	first, kicked := entry(t, addrs[0], "10001")
+	second, _ := entry(t, addrs[1], "10001")
+	select {
+	case reason := <-kicked:
+		if reason != net.KickReasonDuplicateLogin {
+			t.Fatalf("kick reason:%s", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection on other connector not kicked")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !closed(first) || closed(second) {
+		t.Fatalf("first closed:%v second closed:%v", closed(first), closed(second))
+	}
+
+	// 两边同时entry 踢下线的消息交叉 两边结论一致 只保留一个连接
+	for i := 0; i < 5; i++ {
+		uid := strconv.Itoa(20000 + i)
+		clients := make(chan *client.Client, 2)
+		errs := make(chan error, 2)
+		for _, addr := range addrs {
+			go func(addr string) {
+				cli, _, err := dialEntry(addr, uid)
+				if err != nil {
+					errs <- err
+					return
+				}
+				clients <- cli
+			}(addr)
+		}
+		var pair []*client.Client
+		for len(pair) < 2 {
+			select {
+			case cli := <-clients:
+				defer cli.Close()
+				pair = append(pair, cli)
+			case err := <-errs:
+				t.Fatalf("entry err:%v", err)
+			}
+		}
+		a, b := pair[0], pair[1]
+		time.Sleep(300 * time.Millisecond)
+		if closed(a) == closed(b) {
+			t.Fatalf("uid %s concurrent entry closed:%v %v, want exactly one", uid, closed(a), closed(b))
+		}
+	}
+}
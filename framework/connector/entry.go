@@ -0,0 +1,31 @@
+package connector
+
+import (
+	"common/biz"
+	"common/config"
+	"common/jwts"
+	"common/logs"
+	"framework/connector/pb"
+	"framework/net"
+	"framework/waError"
+)
+
+// entryHandler 客户端进入connector
+type entryHandler struct {
+}
+
+// entry 校验gateway签发的token 成功后uid绑定到session
+// 失败时连接保持未认证状态 由net.Manager超时踢下线
+func (h *entryHandler) entry(session *net.Session, req *pb.EntryRequest) (any, *waError.Error) {
+	if req.Token == "" {
+		return nil, biz.RequestDataError
+	}
+	uid, err := jwts.ParseToken(req.Token, config.Conf.Jwt.Secret)
+	if err != nil || uid == "" {
+		logs.Warn("client[%s] entry with invalid token err:%v", session.GetCid(), err)
+		return nil, biz.TokenInfoError
+	}
+	session.SetUid(uid)
+	logs.Info("client[%s] entry success, uid:%s", session.GetCid(), uid)
+	return &pb.EntryResponse{Uid: uid}, nil
+}
@@ -0,0 +1,69 @@
+package connector
+
+import (
+	"common/logs"
+	"encoding/json"
+	"framework/game"
+	"framework/net"
+	"framework/remote"
+)
+
+// PushToUsers 推送给用户 不在本connector上的用户转给其他connector 返回不在线的uid
+// 在线但是payload不能按其序列化方式编码的用户不算不在线 通过*net.UndeliverableError返回
+func (c *Connector) PushToUsers(uids []string, route string, payload any) ([]string, error) {
+	data, err := net.MarshalPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	res := c.wsManager.PushToUsers(uids, route, data)
+	rest := res.Offline(uids)
+	if len(rest) > 0 {
+		res.Merge(remote.Push(c.remoteCli, c.otherConnectors(), &remote.PushRequest{
+			Uids:  rest,
+			Route: route,
+			Data:  data,
+		}, remote.DefaultPushTimeout))
+	}
+	return res.Offline(uids), res.Err(route)
+}
+
+// Broadcast 推送给所有connector上已entry的用户
+func (c *Connector) Broadcast(route string, payload any) error {
+	data, err := net.MarshalPayload(payload)
+	if err != nil {
+		return err
+	}
+	res := c.wsManager.Broadcast(route, data)
+	res.Merge(remote.Push(c.remoteCli, c.otherConnectors(), &remote.PushRequest{
+		Route:     route,
+		Data:      data,
+		Broadcast: true,
+	}, remote.DefaultPushTimeout))
+	return res.Err(route)
+}
+
+// handlePush 其他服务器发来的推送请求 应答推送成功的uid
+func (c *Connector) handlePush(data []byte) []byte {
+	var req remote.PushRequest
+	res := new(net.PushResult)
+	if err := json.Unmarshal(data, &req); err != nil {
+		logs.Error("unmarshal push request err:%v", err)
+	} else if req.Broadcast {
+		res = c.wsManager.Broadcast(req.Route, req.Data)
+	} else {
+		res = c.wsManager.PushToUsers(req.Uids, req.Route, req.Data)
+	}
+	resData, _ := json.Marshal(res)
+	return resData
+}
+
+func (c *Connector) otherConnectors() []string {
+	conf := game.Current()
+	connectors := make([]string, 0, len(conf.ServersConf.Connector))
+	for _, v := range conf.ServersConf.Connector {
+		if v.ID != c.serverId {
+			connectors = append(connectors, v.ID)
+		}
+	}
+	return connectors
+}
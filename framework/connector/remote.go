@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"common/biz"
+	"common/logs"
+	"framework/game"
+	"framework/net"
+	"framework/remote"
+	"framework/waError"
+	"time"
+)
+
+// forward 把hall/game等路由通过nats转发给后端服务器
+// 后端没有启动时nats发布也会成功 由net.Manager按后端的handleTimeOut超时返回错误
+func (c *Connector) forward(session *net.Session, msg *net.Message, route *net.Route) (time.Duration, *waError.Error) {
+	server, ok := c.selectServer(session, route.ServerType)
+	if !ok {
+		logs.Warn("client[%s] route:%s no %s server available", session.GetCid(), msg.Route, route.ServerType)
+		return 0, biz.ServerNotFound
+	}
+	dst := server.ID
+	remoteMsg := &remote.Msg{
+		Type:    remote.RequestMsg,
+		Src:     c.serverId,
+		Dst:     dst,
+		Cid:     session.GetCid(),
+		Uid:     session.GetUid(),
+		MsgId:   msg.ID,
+		MsgType: msg.Type,
+		Route:   msg.Route,
+		Data:    msg.Data,
+		Session: session.Snapshot(),
+	}
+	data, err := remoteMsg.Encode()
+	if err != nil {
+		logs.Error("encode remote msg err:%v", err)
+		return 0, biz.Fail
+	}
+	if err := c.remoteCli.SendMsg(dst, data); err != nil {
+		logs.Error("send remote msg to %s err:%v", dst, err)
+		return 0, biz.Fail
+	}
+	return time.Duration(server.HandleTimeOut) * time.Second, nil
+}
+
+// selectServer 优先使用session绑定的服务器 没有绑定或者已经下线时按配置的方式选一台并绑定
+func (c *Connector) selectServer(session *net.Session, serverType string) (*game.ServersConfig, bool) {
+	conf := game.Current()
+	if serverId := session.GetServer(serverType); serverId != "" && conf.HasServer(serverType, serverId) {
+		return conf.GetServer(serverId), true
+	}
+	server, ok := conf.SelectServer(serverType, c.serverSelect, session.GetUid())
+	if !ok {
+		return nil, false
+	}
+	session.BindServer(serverType, server.ID)
+	return server, true
+}
+
+// remoteReadChanHandler 处理后端发回的响应、推送和session修改
+func (c *Connector) remoteReadChanHandler() {
+	for data := range c.remoteReadChan {
+		msg, err := remote.DecodeMsg(data)
+		if err != nil {
+			logs.Error("decode remote msg err:%v", err)
+			continue
+		}
+		switch msg.Type {
+		case remote.ResponseMsg:
+			c.wsManager.UpdateSession(msg.Session)
+			if err := c.wsManager.Response(msg.Cid, msg.Route, msg.MsgId, msg.Data); err != nil {
+				logs.Warn("response to client[%s] route:%s err:%v", msg.Cid, msg.Route, err)
+			}
+		case remote.PushMsg:
+			if err := c.wsManager.Push(msg.Cid, msg.Route, msg.Data); err != nil {
+				logs.Warn("push to client[%s] route:%s err:%v", msg.Cid, msg.Route, err)
+			}
+		case remote.SessionMsg:
+			c.wsManager.UpdateSession(msg.Session)
+		case remote.KickMsg:
+			c.wsManager.KickUser(msg.Uid, net.KickReasonDuplicateLogin, msg.Src, msg.Seq)
+		default:
+			logs.Warn("unknown remote msg type:%d from %s", msg.Type, msg.Src)
+		}
+	}
+}
+
+// kickOtherConnectors 通知其他connector踢掉同一uid在seq之前entry的连接
+func (c *Connector) kickOtherConnectors(session *net.Session, seq uint64) {
+	for _, dst := range c.otherConnectors() {
+		msg := &remote.Msg{
+			Type: remote.KickMsg,
+			Src:  c.serverId,
+			Dst:  dst,
+			Uid:  session.GetUid(),
+			Seq:  seq,
+		}
+		data, err := msg.Encode()
+		if err != nil {
+			logs.Error("encode kick msg err:%v", err)
+			return
+		}
+		if err := c.remoteCli.SendMsg(dst, data); err != nil {
+			logs.Error("send kick msg to %s err:%v", dst, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package filewatch 监听配置、证书等文件的变化
+package filewatch
+
+import (
+	"common/logs"
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"sync"
+)
+
+// Watcher 监听文件所在的目录 文件通常是整体替换 直接监听文件替换后会失效
+// 兼容k8s的ConfigMap/Secret挂载: 文件是指向..data的符号链接 更新时只有..data被rename
+// 所以每个事件都重新解析符号链接 真实路径变化也视为文件变化
+type Watcher struct {
+	watcher   *fsnotify.Watcher
+	files     []string
+	lock      sync.Mutex
+	realPaths map[string]string
+	onChange  func(changed []string)
+}
+
+// Watch files中的文件变化时调用onChange changed是发生变化的文件
+// onChange在同一个goroutine里依次调用
+func Watch(files []string, onChange func(changed []string)) (*Watcher, error) {
+	w := &Watcher{
+		realPaths: make(map[string]string, len(files)),
+		onChange:  onChange,
+	}
+	dirs := make(map[string]struct{})
+	for _, file := range files {
+		file = filepath.Clean(file)
+		w.files = append(w.files, file)
+		w.realPaths[file] = realPath(file)
+		dirs[filepath.Dir(file)] = struct{}{}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+	}
+	w.watcher = watcher
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if changed := w.changed(event); len(changed) > 0 {
+				w.onChange(changed)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logs.Error("watch files err:%v", err)
+		}
+	}
+}
+
+// changed 事件针对文件本身的写入、创建 或者文件的真实路径变了
+func (w *Watcher) changed(event fsnotify.Event) []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	name := filepath.Clean(event.Name)
+	var changed []string
+	for _, file := range w.files {
+		real := realPath(file)
+		switch {
+		case name == file && event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+			changed = append(changed, file)
+		case real != "" && real != w.realPaths[file]:
+			changed = append(changed, file)
+		default:
+			continue
+		}
+		w.realPaths[file] = real
+	}
+	return changed
+}
+
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+// realPath 解析符号链接 文件不存在时返回空 例如替换过程中
+func realPath(file string) string {
+	real, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return ""
+	}
+	return real
+}
@@ -0,0 +1,80 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitChange(t *testing.T, ch chan []string, want string) {
+	t.Helper()
+	select {
+	case changed := <-ch:
+		for _, v := range changed {
+			if v == want {
+				return
+			}
+		}
+		t.Fatalf("changed = %v, want %s", changed, want)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("change of %s not notified", want)
+	}
+}
+
+func TestWatchWrite(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "servers.json")
+	if err := os.WriteFile(file, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan []string, 16)
+	w, err := Watch([]string{file}, func(changed []string) { ch <- changed })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(`{"a":1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitChange(t, ch, file)
+}
+
+// TestWatchConfigMap k8s挂载的ConfigMap 更新时只rename ..data 文件名本身不会出现在事件中
+func TestWatchConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	writeVersion := func(version, content string) {
+		if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, version, "servers.json"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..v1", "{}")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "servers.json")
+	if err := os.Symlink(filepath.Join("..data", "servers.json"), file); err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan []string, 16)
+	w, err := Watch([]string{file}, func(changed []string) { ch <- changed })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writeVersion("..v2", `{"a":1}`)
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	waitChange(t, ch, file)
+}
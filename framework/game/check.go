@@ -0,0 +1,35 @@
+package game
+
+import (
+	"fmt"
+	"path"
+)
+
+// Check 按Load的规则校验配置目录 返回所有问题而不是第一个 部署前使用
+// 两个文件都能解析时返回的Config不为nil 即使有问题 方便继续做其他检查
+func Check(configDir string) (*Config, []error) {
+	var problems []error
+	gameConfigPath := path.Join(configDir, gameConfigFile)
+	gameConfig, _, err := decodeGameConfig(gameConfigPath)
+	if err != nil {
+		problems = append(problems, err)
+	} else {
+		for _, v := range checkGameConfig(gameConfig) {
+			problems = append(problems, fmt.Errorf("%s: %w", gameConfigPath, v))
+		}
+	}
+	serversPath := path.Join(configDir, serversFile)
+	serversConf, err := decodeServersConfig(serversPath)
+	if err != nil {
+		problems = append(problems, err)
+	} else {
+		for _, v := range checkServersConf(serversConf) {
+			problems = append(problems, fmt.Errorf("%s: %w", serversPath, v))
+		}
+		serversConf.buildTypeServer()
+	}
+	if gameConfig == nil || serversConf == nil {
+		return nil, problems
+	}
+	return &Config{GameConfig: gameConfig, ServersConf: *serversConf}, problems
+}
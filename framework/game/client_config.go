@@ -0,0 +1,49 @@
+package game
+
+import (
+	"encoding/json"
+)
+
+// gameConfigBackendKey 为true的配置项只给服务端使用 不能下发给客户端
+const gameConfigBackendKey = "backend"
+
+// buildClientConfig 导出backend不为true的配置项 key -> value
+// raw直接从json解析 保留配置项的大小写 viper读取的会被转成小写
+func buildClientConfig(raw map[string]GameConfigValue) (map[string]any, json.RawMessage, error) {
+	clientConfig := make(map[string]any)
+	for key, item := range raw {
+		if backend, ok := item[gameConfigBackendKey]; ok {
+			b, err := toBool(backend)
+			// backend值不合法时按服务端配置处理 宁可不下发也不能泄露
+			if err != nil || b {
+				continue
+			}
+		}
+		v, ok := item[gameConfigValueKey]
+		if !ok {
+			continue
+		}
+		clientConfig[key] = v
+	}
+	data, err := json.Marshal(clientConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientConfig, data, nil
+}
+
+// ClientConfig 可以下发给客户端的配置项 只读 不要修改
+func (c *Config) ClientConfig() map[string]any {
+	if c.clientConfig == nil {
+		return map[string]any{}
+	}
+	return c.clientConfig
+}
+
+// ClientConfigJSON ClientConfig序列化后的json 加载时生成 握手时直接使用
+func (c *Config) ClientConfigJSON() json.RawMessage {
+	if c.clientConfigJSON == nil {
+		return json.RawMessage("{}")
+	}
+	return c.clientConfigJSON
+}
@@ -0,0 +1,64 @@
+package game
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	gameConfig := `{
+  "smsAuthConfig": {"value": {"AccessKeySecret": "secret"}, "backend": true},
+  "freeShopItem": {"value": "false", "backend": "true"},
+  "startGold": {"value": 10000, "backend": "yes"},
+  "minRechargeCount": {"value": 20, "backend": 1},
+  "webServerUrl": {"value": "http://127.0.0.1:13000"},
+  "downloadUrl": {"value": "http://127.0.0.1/download", "backend": false},
+  "loopBroadcastContent": {"value": "Wa Cool~~~~", "backend": "false"},
+  "unionConfig": {"value": {"userMaxUnionCount": 20}}
+}`
+	if err := os.WriteFile(filepath.Join(dir, gameConfigFile), []byte(gameConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 只加载gameConfig.json 目录中没有servers.json
+	conf, err := LoadGameConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadGameConfig() err = %v", err)
+	}
+	clientConfig := conf.ClientConfig()
+	for _, key := range []string{"smsAuthConfig", "freeShopItem", "startGold", "minRechargeCount"} {
+		if _, ok := clientConfig[key]; ok {
+			t.Fatalf("backend only %s exported", key)
+		}
+	}
+	// 保留配置项和value中字段的大小写
+	want := map[string]any{
+		"webServerUrl":         "http://127.0.0.1:13000",
+		"downloadUrl":          "http://127.0.0.1/download",
+		"loopBroadcastContent": "Wa Cool~~~~",
+		"unionConfig":          map[string]any{"userMaxUnionCount": float64(20)},
+	}
+	var got map[string]any
+	if err := json.Unmarshal(conf.ClientConfigJSON(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) || len(clientConfig) != len(want) {
+		t.Fatalf("client config = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if b, _ := json.Marshal(got[k]); string(b) != mustMarshal(t, v) {
+			t.Fatalf("client config %s = %s, want %v", k, b, v)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
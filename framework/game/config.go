@@ -1,39 +1,45 @@
 package game
 
 import (
-	"common/logs"
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
-	"log"
 	"os"
 	"path"
 )
 
-var Conf *Config
-
 const (
-	gameConfig = "gameConfig.json"
-	servers    = "servers.json"
+	gameConfigFile = "gameConfig.json"
+	serversFile    = "servers.json"
 )
 
+// Config 一份完整的配置快照 加载后不再修改 通过Current获取当前快照
 type Config struct {
 	GameConfig  map[string]GameConfigValue `json:"gameConfig"`
 	ServersConf ServersConf                `json:"serversConf"`
+	// clientConfig 可以下发给客户端的配置项 加载时生成
+	clientConfig     map[string]any
+	clientConfigJSON json.RawMessage
 }
 type ServersConf struct {
-	Nats       NatsConfig         `json:"nats"`
-	Connector  []*ConnectorConfig `json:"connector"`
-	Servers    []*ServersConfig   `json:"servers"`
+	Nats      NatsConfig         `json:"nats"`
+	Connector []*ConnectorConfig `json:"connector"`
+	Servers   []*ServersConfig   `json:"servers"`
+	// TypeServer serverType -> 服务器列表 每次加载时重新生成
 	TypeServer map[string][]*ServersConfig
+	// rings serverType -> 一致性哈希环 随TypeServer一起生成
+	rings map[string]*hashRing
 }
 
 type ServersConfig struct {
-	ID               string `json:"id"`
-	ServerType       string `json:"serverType"`
-	HandleTimeOut    int    `json:"handleTimeOut"`
-	RPCTimeOut       int    `json:"rpcTimeOut"`
-	MaxRunRoutineNum int    `json:"maxRunRoutineNum"`
+	ID         string `json:"id"`
+	ServerType string `json:"serverType"`
+	// HandleTimeOut 处理请求的最长时间 秒 connector转发后超过这个时间没有响应时返回超时错误
+	HandleTimeOut int `json:"handleTimeOut"`
+	// RPCTimeOut 服务器之间推送的超时时间 秒
+	RPCTimeOut       int `json:"rpcTimeOut"`
+	MaxRunRoutineNum int `json:"maxRunRoutineNum"`
 }
 
 type ConnectorConfig struct {
@@ -41,7 +47,40 @@ type ConnectorConfig struct {
 	Host       string `json:"host"`
 	ClientPort int    `json:"clientPort"`
 	Frontend   bool   `json:"frontend"`
+	HeartTime  int    `json:"heartTime"` // 心跳间隔 秒
 	ServerType string `json:"serverType"`
+	// WriteQueueSize 每个连接的写队列长度 默认1024
+	WriteQueueSize int `json:"writeQueueSize"`
+	// SlowConsumer 写队列满时推送的处理方式 drop丢弃 close断开连接 默认drop
+	SlowConsumer string `json:"slowConsumer"`
+	// RateLimit 请求限流 不配置表示不限制
+	RateLimit *RateLimitConfig `json:"rateLimit"`
+	// CompressThreshold 消息体超过这个字节数时gzip压缩 客户端握手时声明支持才会压缩 0表示不压缩
+	CompressThreshold int `json:"compressThreshold"`
+	// CertFile KeyFile 证书和私钥路径 都配置时客户端使用wss连接 文件变化后自动重新加载
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ServerSelect 选择hall/game服务器的方式 random、roundRobin、hash(按uid一致性哈希) 默认hash
+	ServerSelect string `json:"serverSelect"`
+	// Routes hall/game等后端的请求和推送路由 加入路由字典 客户端可以用2字节编码代替路由字符串
+	Routes []string `json:"routes"`
+	// ShutdownTimeout 停机时等待请求处理完的最长时间 秒 默认10
+	ShutdownTimeout int `json:"shutdownTimeout"`
+}
+
+// RateLimitConfig 令牌桶限流 rate每秒请求数 burst允许的突发请求数
+type RateLimitConfig struct {
+	Rate          float64             `json:"rate"`
+	Burst         int                 `json:"burst"`
+	MaxViolations int                 `json:"maxViolations"` // 一分钟内超限次数达到后踢下线
+	Routes        []*RouteLimitConfig `json:"routes"`
+}
+
+// RouteLimitConfig 单个路由的限流 路由中带有"." 所以用列表配置
+type RouteLimitConfig struct {
+	Route string  `json:"route"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
 }
 type NatsConfig struct {
 	Url string `json:"url"`
@@ -49,22 +88,62 @@ type NatsConfig struct {
 
 type GameConfigValue map[string]any
 
-// InitConfig 自动加载指定目录下约定的配置文件
+// load InitConfig或者InitGameConfig选择的加载方式 重新加载时使用同一种
+var load = Load
+
+// InitConfig 加载指定目录下约定的配置文件 配置不合法时直接退出 之后文件修改会自动重新加载
 func InitConfig(configDir string) {
-	Conf = new(Config)
-	dir, err := os.ReadDir(configDir)
+	initConfig(configDir, Load)
+}
+
+// InitGameConfig 只加载gameConfig.json 用于gateway这样不连接nats、不需要servers.json的服务
+func InitGameConfig(configDir string) {
+	initConfig(configDir, LoadGameConfig)
+}
+
+func initConfig(configDir string, loader func(string) (*Config, error)) {
+	conf, err := loader(configDir)
 	if err != nil {
-		logs.Fatal("read config dir err:%v", err)
+		panic(fmt.Errorf("加载配置文件报错，err:%v \n", err))
 	}
-	for _, v := range dir {
-		configFile := path.Join(configDir, v.Name())
-		if v.Name() == gameConfig {
-			readGameConfig(configFile)
-		}
-		if v.Name() == servers {
-			readServesConfig(configFile)
-		}
+	load = loader
+	current.Store(conf)
+	watch(configDir)
+}
+
+// Load 读取并校验配置目录下的gameConfig.json和servers.json 生成新的快照
+func Load(configDir string) (*Config, error) {
+	conf, err := LoadGameConfig(configDir)
+	if err != nil {
+		return nil, err
+	}
+	serversConf, err := readServersConfig(path.Join(configDir, serversFile))
+	if err != nil {
+		return nil, err
+	}
+	conf.ServersConf = *serversConf
+	return conf, nil
+}
+
+// LoadGameConfig 只读取并校验gameConfig.json 快照中的ServersConf为空
+func LoadGameConfig(configDir string) (*Config, error) {
+	gameConfigPath := path.Join(configDir, gameConfigFile)
+	gameConfig, raw, err := readGameConfig(gameConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig, clientConfigJSON, err := buildClientConfig(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: export client config err:%v", gameConfigPath, err)
+	}
+	conf := &Config{
+		GameConfig: gameConfig,
+
+		clientConfig:     clientConfig,
+		clientConfigJSON: clientConfigJSON,
 	}
+	conf.ServersConf.buildTypeServer()
+	return conf, nil
 }
 
 func (c *Config) GetConnector(serverId string) *ConnectorConfig {
@@ -75,6 +154,15 @@ func (c *Config) GetConnector(serverId string) *ConnectorConfig {
 	}
 	return nil
 }
+func (c *Config) GetServer(serverId string) *ServersConfig {
+	for _, v := range c.ServersConf.Servers {
+		if v.ID == serverId {
+			return v
+		}
+	}
+	return nil
+}
+
 func (c *Config) GetConnectorByServerType(serverType string) *ConnectorConfig {
 	for _, v := range c.ServersConf.Connector {
 		if v.ServerType == serverType {
@@ -84,65 +172,108 @@ func (c *Config) GetConnectorByServerType(serverType string) *ConnectorConfig {
 	return nil
 }
 
-func readServesConfig(configFile string) {
+func readServersConfig(configFile string) (*ServersConf, error) {
+	serversConf, err := decodeServersConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if problems := checkServersConf(serversConf); len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %w", configFile, problems[0])
+	}
+	serversConf.buildTypeServer()
+	return serversConf, nil
+}
+
+func decodeServersConfig(configFile string) (*ServersConf, error) {
 	var serversConf ServersConf
 	v := viper.New()
 	v.SetConfigFile(configFile)
-	v.WatchConfig()
-	v.OnConfigChange(func(e fsnotify.Event) {
-		log.Println("serversConf配置文件被修改")
-		err := v.Unmarshal(&serversConf)
-		if err != nil {
-			panic(fmt.Errorf("serversConf配置文件被修改以后，报错，err:%v \n", err))
-		}
-		Conf.ServersConf = serversConf
-		typeServerConfig()
-	})
-	err := v.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("读取serversConf配置文件报错，err:%v \n", err))
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read %s err:%v", configFile, err)
 	}
 	if err := v.Unmarshal(&serversConf); err != nil {
-		panic(fmt.Errorf("Unmarshal data to Conf failed ，err:%v \n", err))
+		return nil, fmt.Errorf("unmarshal %s err:%v", configFile, err)
 	}
-	Conf.ServersConf = serversConf
-	typeServerConfig()
-
+	return &serversConf, nil
 }
 
-func typeServerConfig() {
-	if len(Conf.ServersConf.Servers) > 0 {
-		if Conf.ServersConf.TypeServer == nil {
-			Conf.ServersConf.TypeServer = make(map[string][]*ServersConfig)
+// checkServersConf 服务器id不能重复 connector必须配置端口 返回所有问题
+func checkServersConf(conf *ServersConf) []error {
+	var problems []error
+	if conf.Nats.Url == "" {
+		problems = append(problems, fmt.Errorf("nats url is required"))
+	}
+	ids := make(map[string]struct{})
+	checkId := func(id string) {
+		if id == "" {
+			return
+		}
+		if _, ok := ids[id]; ok {
+			problems = append(problems, fmt.Errorf("duplicate server id %s", id))
 		}
-		for _, v := range Conf.ServersConf.Servers {
-			if Conf.ServersConf.TypeServer[v.ServerType] == nil {
-				Conf.ServersConf.TypeServer[v.ServerType] = make([]*ServersConfig, 0, 10)
-			}
-			Conf.ServersConf.TypeServer[v.ServerType] = append(Conf.ServersConf.TypeServer[v.ServerType], v)
+		ids[id] = struct{}{}
+	}
+	for _, v := range conf.Connector {
+		if v.ID == "" || v.ClientPort <= 0 {
+			problems = append(problems, fmt.Errorf("connector %q without id or clientPort", v.ID))
+		}
+		switch SelectStrategy(v.ServerSelect) {
+		case "", SelectRandom, SelectRoundRobin, SelectHash:
+		default:
+			problems = append(problems, fmt.Errorf("connector %s unknown serverSelect %q", v.ID, v.ServerSelect))
+		}
+		checkId(v.ID)
+	}
+	for _, v := range conf.Servers {
+		if v.ID == "" || v.ServerType == "" {
+			problems = append(problems, fmt.Errorf("server %q without id or serverType", v.ID))
 		}
+		checkId(v.ID)
 	}
+	return problems
 }
 
-func readGameConfig(configFile string) {
+// buildTypeServer 每次加载都重新生成 不会保留上一次的服务器
+func (s *ServersConf) buildTypeServer() {
+	s.TypeServer = make(map[string][]*ServersConfig)
+	for _, v := range s.Servers {
+		s.TypeServer[v.ServerType] = append(s.TypeServer[v.ServerType], v)
+	}
+	s.rings = make(map[string]*hashRing, len(s.TypeServer))
+	for serverType, servers := range s.TypeServer {
+		s.rings[serverType] = newHashRing(servers)
+	}
+}
+
+func readGameConfig(configFile string) (map[string]GameConfigValue, map[string]GameConfigValue, error) {
+	gameConfig, raw, err := decodeGameConfig(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if problems := checkGameConfig(gameConfig); len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%s: %w", configFile, problems[0])
+	}
+	return gameConfig, raw, nil
+}
+
+// decodeGameConfig 同一份内容用viper解析一次 再用json解析一次保留原始大小写的raw 用于导出客户端配置
+func decodeGameConfig(configFile string) (map[string]GameConfigValue, map[string]GameConfigValue, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s err:%v", configFile, err)
+	}
 	var gameConfig = make(map[string]GameConfigValue)
 	v := viper.New()
-	v.SetConfigFile(configFile)
-	v.WatchConfig()
-	v.OnConfigChange(func(e fsnotify.Event) {
-		log.Println("gameConfig配置文件被修改")
-		err := v.Unmarshal(&gameConfig)
-		if err != nil {
-			panic(fmt.Errorf("gameConfig配置文件被修改以后，报错，err:%v \n", err))
-		}
-		Conf.GameConfig = gameConfig
-	})
-	err := v.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("读取gameConfig配置文件报错，err:%v \n", err))
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, nil, fmt.Errorf("read %s err:%v", configFile, err)
 	}
 	if err := v.Unmarshal(&gameConfig); err != nil {
-		panic(fmt.Errorf("Unmarshal data to Conf failed ，err:%v \n", err))
+		return nil, nil, fmt.Errorf("unmarshal %s err:%v", configFile, err)
+	}
+	var raw = make(map[string]GameConfigValue)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal %s err:%v", configFile, err)
 	}
-	Conf.GameConfig = gameConfig
+	return gameConfig, raw, nil
 }
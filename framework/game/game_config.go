@@ -0,0 +1,299 @@
+package game
+
+import (
+	"common/logs"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gameConfigValueKey gameConfig.json中每一项的值放在value里 describe、backend是说明
+const gameConfigValueKey = "value"
+
+var (
+	ErrGameConfigNotFound  = errors.New("game config not found")
+	ErrGameConfigWrongType = errors.New("game config wrong type")
+)
+
+// ValueKind 配置值的类型 加载时按类型校验
+type ValueKind string
+
+const (
+	KindInt      ValueKind = "int"
+	KindBool     ValueKind = "bool"
+	KindString   ValueKind = "string"
+	KindDuration ValueKind = "duration" // "10s"这样的字符串或者秒数
+	KindObject   ValueKind = "object"
+	KindArray    ValueKind = "array"
+)
+
+// GameConfigKey 加载gameConfig.json时校验的配置项 Key可以用"."访问value中的字段
+type GameConfigKey struct {
+	Key      string
+	Kind     ValueKind
+	Required bool
+}
+
+var (
+	gameConfigKeysLock sync.RWMutex
+	// gameConfigKeys 各服务注册的配置项 缺少必填项或者类型不对时加载失败
+	// 框架本身不使用gameConfig 默认为空 只用到部分配置的服务不会因为其他服务的配置启动失败
+	gameConfigKeys []GameConfigKey
+)
+
+// RegisterGameConfigKey 各服务在InitConfig之前注册自己用到的配置项 例如hall
+//
+//	game.RegisterGameConfigKey(
+//		game.GameConfigKey{Key: "startGold", Kind: game.KindInt, Required: true},
+//		game.GameConfigKey{Key: "unionConfig.userMaxUnionCount", Kind: game.KindInt, Required: true},
+//	)
+func RegisterGameConfigKey(keys ...GameConfigKey) {
+	gameConfigKeysLock.Lock()
+	defer gameConfigKeysLock.Unlock()
+	gameConfigKeys = append(gameConfigKeys, keys...)
+}
+
+// checkGameConfig 校验所有注册的配置项 返回所有问题
+func checkGameConfig(gameConfig map[string]GameConfigValue) []error {
+	gameConfigKeysLock.RLock()
+	defer gameConfigKeysLock.RUnlock()
+	var problems []error
+	for _, k := range gameConfigKeys {
+		v, ok := lookupGameConfig(gameConfig, k.Key)
+		if !ok {
+			if k.Required {
+				problems = append(problems, fmt.Errorf("%w: %s is required", ErrGameConfigNotFound, k.Key))
+			}
+			continue
+		}
+		if err := checkKind(v, k.Kind); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", k.Key, err))
+		}
+	}
+	return problems
+}
+
+func checkKind(v any, kind ValueKind) error {
+	var err error
+	switch kind {
+	case KindInt:
+		_, err = toInt(v)
+	case KindBool:
+		_, err = toBool(v)
+	case KindString:
+		_, err = toString(v)
+	case KindDuration:
+		_, err = toDuration(v)
+	case KindObject:
+		if _, ok := v.(map[string]any); !ok {
+			err = wrongType(v, kind)
+		}
+	case KindArray:
+		if _, ok := v.([]any); !ok {
+			err = wrongType(v, kind)
+		}
+	default:
+		err = fmt.Errorf("unknown kind %s", kind)
+	}
+	return err
+}
+
+// lookupGameConfig key的第一段是配置项 后面是value中的字段
+// viper读取时会把key转成小写 所以不区分大小写
+func lookupGameConfig(gameConfig map[string]GameConfigValue, key string) (any, bool) {
+	parts := strings.Split(key, ".")
+	item, ok := gameConfig[parts[0]]
+	if !ok {
+		item, ok = gameConfig[strings.ToLower(parts[0])]
+	}
+	if !ok {
+		return nil, false
+	}
+	v, ok := item[gameConfigValueKey]
+	if !ok {
+		return nil, false
+	}
+	for _, part := range parts[1:] {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = fieldOf(m, part); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func fieldOf(m map[string]any, name string) (any, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// GameValue 配置项的原始值 key可以是 unionConfig.userMaxUnionCount
+func (c *Config) GameValue(key string) (any, bool) {
+	return lookupGameConfig(c.GameConfig, key)
+}
+
+// Int 配置项不存在或者不是整数时返回错误
+func (c *Config) Int(key string) (int, error) {
+	v, ok := c.GameValue(key)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrGameConfigNotFound, key)
+	}
+	return toInt(v)
+}
+
+func (c *Config) Bool(key string) (bool, error) {
+	v, ok := c.GameValue(key)
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrGameConfigNotFound, key)
+	}
+	return toBool(v)
+}
+
+func (c *Config) String(key string) (string, error) {
+	v, ok := c.GameValue(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrGameConfigNotFound, key)
+	}
+	return toString(v)
+}
+
+func (c *Config) Duration(key string) (time.Duration, error) {
+	v, ok := c.GameValue(key)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrGameConfigNotFound, key)
+	}
+	return toDuration(v)
+}
+
+// Decode 把对象类型的配置项解析到结构体 字段名不区分大小写 "false"这样的字符串会转换成对应类型
+func (c *Config) Decode(key string, out any) error {
+	v, ok := c.GameValue(key)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrGameConfigNotFound, key)
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %s %v", ErrGameConfigWrongType, key, err)
+	}
+	return nil
+}
+
+// GetInt 配置项不存在或者类型不对时返回def
+func (c *Config) GetInt(key string, def int) int {
+	v, err := c.Int(key)
+	return orDefault(key, v, def, err)
+}
+
+func (c *Config) GetBool(key string, def bool) bool {
+	v, err := c.Bool(key)
+	return orDefault(key, v, def, err)
+}
+
+func (c *Config) GetString(key string, def string) string {
+	v, err := c.String(key)
+	return orDefault(key, v, def, err)
+}
+
+func (c *Config) GetDuration(key string, def time.Duration) time.Duration {
+	v, err := c.Duration(key)
+	return orDefault(key, v, def, err)
+}
+
+func orDefault[T any](key string, v, def T, err error) T {
+	if err == nil {
+		return v
+	}
+	if !errors.Is(err, ErrGameConfigNotFound) {
+		logs.Warn("game config %s err:%v, use default %v", key, err, def)
+	}
+	return def
+}
+
+func wrongType(v any, kind ValueKind) error {
+	return fmt.Errorf("%w: want %s, got %T(%v)", ErrGameConfigWrongType, kind, v, v)
+}
+
+// toInt json数字解析出来是float64 带小数时视为类型错误 数字字符串也可以
+func toInt(v any) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, wrongType(v, KindInt)
+		}
+		return int(n), nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil {
+			return 0, wrongType(v, KindInt)
+		}
+		return i, nil
+	}
+	return 0, wrongType(v, KindInt)
+}
+
+// toBool 兼容配置中"true"/"false"这样的字符串
+func toBool(v any) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		res, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return false, wrongType(v, KindBool)
+		}
+		return res, nil
+	}
+	return false, wrongType(v, KindBool)
+}
+
+func toString(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", wrongType(v, KindString)
+}
+
+// toDuration 字符串按time.ParseDuration解析 数字表示秒
+func toDuration(v any) (time.Duration, error) {
+	switch d := v.(type) {
+	case string:
+		res, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return 0, wrongType(v, KindDuration)
+		}
+		return res, nil
+	case int, int64, float64:
+		n, err := toInt(d)
+		if err != nil {
+			return 0, wrongType(v, KindDuration)
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+	return 0, wrongType(v, KindDuration)
+}
@@ -0,0 +1,156 @@
+package game
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testServersConfig = `{
+  "nats": {"url": "nats://localhost:4222"},
+  "connector": [{"id": "connector001", "clientPort": 12000, "serverType": "connector"}],
+  "servers": [{"id": "hall-001", "serverType": "hall"}]
+}`
+
+// writeConfigDir 生成只包含gameConfig.json和servers.json的配置目录
+func writeConfigDir(t *testing.T, gameConfig string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, gameConfigFile), []byte(gameConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, serversFile), []byte(testServersConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// registerKeys 注册的配置项在测试结束后恢复
+func registerKeys(t *testing.T, keys ...GameConfigKey) {
+	gameConfigKeysLock.Lock()
+	old := gameConfigKeys
+	gameConfigKeysLock.Unlock()
+	t.Cleanup(func() {
+		gameConfigKeysLock.Lock()
+		gameConfigKeys = old
+		gameConfigKeysLock.Unlock()
+	})
+	RegisterGameConfigKey(keys...)
+}
+
+func TestToInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		want    int
+		wantErr bool
+	}{
+		{"int", 10, 10, false},
+		{"json number", float64(10000), 10000, false},
+		{"fraction", 1.5, 0, true},
+		{"numeric string", " 20 ", 20, false},
+		{"non numeric string", "abc", 0, true},
+		{"bool", true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toInt(tt.v)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("toInt(%v) = %d, %v, want %d", tt.v, got, err, tt.want)
+			}
+			if err != nil && !errors.Is(err, ErrGameConfigWrongType) {
+				t.Fatalf("toInt(%v) err = %v, want ErrGameConfigWrongType", tt.v, err)
+			}
+		})
+	}
+}
+
+func TestToBool(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		want    bool
+		wantErr bool
+	}{
+		{"true", true, true, false},
+		{"false", false, false, false},
+		// "false"字符串不能当成非空字符串处理成true
+		{"false string", "false", false, false},
+		{"true string", "true", true, false},
+		{"other string", "yes", false, true},
+		{"number", float64(1), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toBool(tt.v)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("toBool(%v) = %v, %v, want %v", tt.v, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestToDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		want    time.Duration
+		wantErr bool
+	}{
+		{"duration string", "1m30s", 90 * time.Second, false},
+		{"seconds", float64(10), 10 * time.Second, false},
+		{"int seconds", 3, 3 * time.Second, false},
+		{"fraction seconds", 1.5, 0, true},
+		{"invalid string", "10", 0, true},
+		{"bool", false, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toDuration(tt.v)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("toDuration(%v) = %v, %v, want %v", tt.v, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRequiredKey(t *testing.T) {
+	// 默认不要求任何配置项
+	dir := writeConfigDir(t, `{"downloadUrl": {"value": "http://127.0.0.1/download"}}`)
+	if _, err := Load(dir); err != nil {
+		t.Fatalf("Load() without registered keys err = %v", err)
+	}
+
+	registerKeys(t,
+		GameConfigKey{Key: "startGold", Kind: KindInt, Required: true},
+		GameConfigKey{Key: "freeShopItem", Kind: KindBool},
+	)
+	if _, err := Load(dir); !errors.Is(err, ErrGameConfigNotFound) {
+		t.Fatalf("Load() missing required key err = %v, want ErrGameConfigNotFound", err)
+	}
+
+	dir = writeConfigDir(t, `{"startGold": {"value": 10000}, "freeShopItem": {"value": "no"}}`)
+	if _, err := Load(dir); !errors.Is(err, ErrGameConfigWrongType) {
+		t.Fatalf("Load() wrong type err = %v, want ErrGameConfigWrongType", err)
+	}
+
+	dir = writeConfigDir(t, `{"startGold": {"value": 10000}, "freeShopItem": {"value": "false"}}`)
+	conf, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() err = %v", err)
+	}
+	if v := conf.GetInt("startGold", 0); v != 10000 {
+		t.Fatalf("GetInt(startGold) = %d", v)
+	}
+	if v := conf.GetBool("freeShopItem", true); v {
+		t.Fatalf("GetBool(freeShopItem) = %v, want false", v)
+	}
+
+	// Check报告所有问题而不是第一个
+	dir = writeConfigDir(t, `{"freeShopItem": {"value": "no"}}`)
+	if _, problems := Check(dir); len(problems) != 2 {
+		t.Fatalf("Check() problems = %v, want 2", problems)
+	}
+}
@@ -0,0 +1,122 @@
+package game
+
+import (
+	"common/logs"
+	"framework/filewatch"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServersKey 订阅servers.json的修改
+const ServersKey = "servers"
+
+// reloadDelay 编辑器保存时会连续产生多个事件 合并后再加载
+const reloadDelay = 200 * time.Millisecond
+
+// ChangeHandler 配置修改并生效后调用 old是修改前的快照
+type ChangeHandler func(old, new *Config)
+
+var (
+	current    atomic.Pointer[Config]
+	emptyConf  = &Config{}
+	reloadLock sync.Mutex
+
+	subscribersLock sync.RWMutex
+	subscribers     = make(map[int]*subscriber)
+	subscriberId    int
+)
+
+type subscriber struct {
+	key     string
+	handler ChangeHandler
+}
+
+// Current 当前生效的配置快照 InitConfig之前返回空配置 快照只读 不要修改
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return emptyConf
+}
+
+// Subscribe 订阅配置项的修改 key是gameConfig中的配置项(可以用"."访问value中的字段)或者ServersKey
+// 返回的函数用于取消订阅
+func Subscribe(key string, handler ChangeHandler) func() {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	subscriberId++
+	id := subscriberId
+	subscribers[id] = &subscriber{key: key, handler: handler}
+	return func() {
+		subscribersLock.Lock()
+		defer subscribersLock.Unlock()
+		delete(subscribers, id)
+	}
+}
+
+// watch 监听配置文件 文件修改后重新加载 兼容k8s ConfigMap挂载的符号链接
+func watch(configDir string) {
+	var timer *time.Timer
+	files := []string{filepath.Join(configDir, gameConfigFile), filepath.Join(configDir, serversFile)}
+	_, err := filewatch.Watch(files, func([]string) {
+		if timer == nil {
+			timer = time.AfterFunc(reloadDelay, func() { reload(configDir) })
+		} else {
+			timer.Reset(reloadDelay)
+		}
+	})
+	if err != nil {
+		logs.Error("watch config dir err:%v", err)
+	}
+}
+
+// reload 生成新的快照 校验失败时继续使用旧配置
+func reload(configDir string) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	conf, err := load(configDir)
+	if err != nil {
+		logs.Error("reload config rejected, keep the old one, err:%v", err)
+		return
+	}
+	old := Current()
+	current.Store(conf)
+	logs.Info("reload config success")
+	notify(old, conf)
+}
+
+func notify(old, new *Config) {
+	subscribersLock.RLock()
+	subs := make([]*subscriber, 0, len(subscribers))
+	for _, v := range subscribers {
+		subs = append(subs, v)
+	}
+	subscribersLock.RUnlock()
+	for _, v := range subs {
+		if changed(old, new, v.key) {
+			callHandler(v, old, new)
+		}
+	}
+}
+
+// callHandler 订阅者panic不影响其他订阅者
+func callHandler(s *subscriber, old, new *Config) {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.Error("config change handler of %s panic:%v", s.key, err)
+		}
+	}()
+	s.handler(old, new)
+}
+
+func changed(old, new *Config, key string) bool {
+	if key == ServersKey {
+		return !reflect.DeepEqual(old.ServersConf, new.ServersConf)
+	}
+	oldValue, oldOk := old.GameValue(key)
+	newValue, newOk := new.GameValue(key)
+	return oldOk != newOk || !reflect.DeepEqual(oldValue, newValue)
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"common/config"
+	"common/logs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// calls 记录订阅者被调用的次数
+type calls struct {
+	sync.Mutex
+	count map[string]int
+}
+
+func (c *calls) subscribe(t *testing.T, key string) {
+	t.Cleanup(Subscribe(key, func(old, new *Config) {
+		c.Lock()
+		defer c.Unlock()
+		c.count[key]++
+	}))
+}
+
+func (c *calls) get() map[string]int {
+	c.Lock()
+	defer c.Unlock()
+	res := make(map[string]int, len(c.count))
+	for k, v := range c.count {
+		res[k] = v
+	}
+	return res
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitConfig 等待快照满足条件 超时返回false
+func waitConfig(done func(conf *Config) bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if done(Current()) {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReload(t *testing.T) {
+	config.Conf = &config.Config{}
+	logs.InitLog("test")
+	dir := writeConfigDir(t, `{"startGold": {"value": 10000}, "downloadUrl": {"value": "http://127.0.0.1/download"}}`)
+	t.Cleanup(func() {
+		current.Store(nil)
+		load = Load
+	})
+	InitConfig(dir)
+	c := &calls{count: make(map[string]int)}
+	for _, key := range []string{"startGold", "downloadUrl", ServersKey} {
+		c.subscribe(t, key)
+	}
+	first := Current()
+
+	// 格式错误的servers.json不生效 继续使用旧快照
+	writeFile(t, dir, serversFile, `{"nats": `)
+	time.Sleep(4 * reloadDelay)
+	if Current() != first {
+		t.Fatal("malformed servers.json replaced the snapshot")
+	}
+	if got := c.get(); len(got) != 0 {
+		t.Fatalf("subscribers called %v for rejected config", got)
+	}
+
+	// 只修改servers.json 只有订阅servers的被调用
+	servers := `{
+  "nats": {"url": "nats://localhost:4222"},
+  "connector": [{"id": "connector001", "clientPort": 12000, "serverType": "connector"}],
+  "servers": [{"id": "hall-001", "serverType": "hall"}, {"id": "hall-002", "serverType": "hall"}]
+}`
+	writeFile(t, dir, serversFile, servers)
+	if !waitConfig(func(conf *Config) bool { return len(conf.ServersConf.TypeServer["hall"]) == 2 }) {
+		t.Fatal("servers.json not reloaded")
+	}
+	time.Sleep(2 * reloadDelay)
+	if got := c.get(); len(got) != 1 || got[ServersKey] != 1 {
+		t.Fatalf("subscribers called %v, want only %s once", got, ServersKey)
+	}
+	if first.ServersConf.TypeServer["hall"][0].ID != "hall-001" || len(first.ServersConf.TypeServer["hall"]) != 1 {
+		t.Fatal("old snapshot modified")
+	}
+
+	// 只修改startGold
+	writeFile(t, dir, gameConfigFile, `{"startGold": {"value": 20000}, "downloadUrl": {"value": "http://127.0.0.1/download"}}`)
+	if !waitConfig(func(conf *Config) bool { return conf.GetInt("startGold", 0) == 20000 }) {
+		t.Fatal("gameConfig.json not reloaded")
+	}
+	time.Sleep(2 * reloadDelay)
+	if got := c.get(); len(got) != 2 || got["startGold"] != 1 || got[ServersKey] != 1 {
+		t.Fatalf("subscribers called %v, want startGold and %s once", got, ServersKey)
+	}
+}
+
+// TestSubscribePanic 订阅者panic不影响其他订阅者 取消订阅后不再调用
+func TestSubscribePanic(t *testing.T) {
+	old := &Config{}
+	new := &Config{GameConfig: map[string]GameConfigValue{"startGold": {"value": float64(1)}}}
+	c := &calls{count: make(map[string]int)}
+	t.Cleanup(Subscribe("startGold", func(old, new *Config) { panic("subscriber panic") }))
+	c.subscribe(t, "startGold")
+	unsubscribe := Subscribe("startGold", func(old, new *Config) { t.Fatal("unsubscribed handler called") })
+	unsubscribe()
+	notify(old, new)
+	if got := c.get(); got["startGold"] != 1 {
+		t.Fatalf("subscribers called %v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// SelectStrategy 选择后端服务器的方式
+type SelectStrategy string
+
+const (
+	SelectRandom     SelectStrategy = "random"
+	SelectRoundRobin SelectStrategy = "roundRobin"
+	// SelectHash 按uid一致性哈希 服务器增减时只有少量用户换服务器
+	SelectHash SelectStrategy = "hash"
+)
+
+// ringReplicas 一致性哈希中每台服务器的虚拟节点数
+const ringReplicas = 160
+
+// roundRobinCounters serverType -> 计数 跨配置快照保留
+var roundRobinCounters sync.Map
+
+// hashRing 一致性哈希环 加载配置时生成 之后只读
+type hashRing struct {
+	hashes  []uint32
+	servers map[uint32]*ServersConfig
+}
+
+func newHashRing(servers []*ServersConfig) *hashRing {
+	r := &hashRing{
+		hashes:  make([]uint32, 0, len(servers)*ringReplicas),
+		servers: make(map[uint32]*ServersConfig, len(servers)*ringReplicas),
+	}
+	for _, v := range servers {
+		for i := 0; i < ringReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(v.ID + "#" + strconv.Itoa(i)))
+			if _, ok := r.servers[h]; ok {
+				continue
+			}
+			r.servers[h] = v
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// get 顺时针找到第一个虚拟节点
+func (r *hashRing) get(key string) *ServersConfig {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.servers[r.hashes[i]]
+}
+
+// RandomServer 随机选择一台serverType类型的服务器
+func (c *Config) RandomServer(serverType string) (*ServersConfig, bool) {
+	servers := c.ServersConf.TypeServer[serverType]
+	if len(servers) == 0 {
+		return nil, false
+	}
+	return servers[rand.Intn(len(servers))], true
+}
+
+// RoundRobinServer 依次选择serverType类型的服务器
+func (c *Config) RoundRobinServer(serverType string) (*ServersConfig, bool) {
+	servers := c.ServersConf.TypeServer[serverType]
+	if len(servers) == 0 {
+		return nil, false
+	}
+	v, _ := roundRobinCounters.LoadOrStore(serverType, new(uint64))
+	n := atomic.AddUint64(v.(*uint64), 1)
+	return servers[(n-1)%uint64(len(servers))], true
+}
+
+// HashServer 按key一致性哈希选择服务器 同一个key在服务器列表不变时总是选到同一台
+func (c *Config) HashServer(serverType, key string) (*ServersConfig, bool) {
+	ring, ok := c.ServersConf.rings[serverType]
+	if !ok {
+		return nil, false
+	}
+	server := ring.get(key)
+	return server, server != nil
+}
+
+// SelectServer 按strategy选择服务器 key只在SelectHash时使用 strategy为空时随机选择 未知的strategy加载配置时已拒绝
+func (c *Config) SelectServer(serverType string, strategy SelectStrategy, key string) (*ServersConfig, bool) {
+	switch strategy {
+	case SelectRoundRobin:
+		return c.RoundRobinServer(serverType)
+	case SelectHash:
+		return c.HashServer(serverType, key)
+	default:
+		return c.RandomServer(serverType)
+	}
+}
+
+// HasServer serverType类型中是否有这台服务器
+func (c *Config) HasServer(serverType, serverId string) bool {
+	for _, v := range c.ServersConf.TypeServer[serverType] {
+		if v.ID == serverId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testConfig 生成serverType为hall的服务器
+func testConfig(ids ...string) *Config {
+	conf := &Config{}
+	for _, id := range ids {
+		conf.ServersConf.Servers = append(conf.ServersConf.Servers, &ServersConfig{ID: id, ServerType: "hall"})
+	}
+	conf.ServersConf.buildTypeServer()
+	return conf
+}
+
+func TestHashServer(t *testing.T) {
+	conf := testConfig("hall-001", "hall-002", "hall-003")
+	tests := []struct {
+		name string
+		uid  string
+	}{
+		{"numeric uid", "10001"},
+		{"another uid", "10002"},
+		{"empty uid", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, ok := conf.HashServer("hall", tt.uid)
+			if !ok {
+				t.Fatalf("HashServer(%q) not found", tt.uid)
+			}
+			// 同一个uid多次选择以及重新加载配置后都选到同一台
+			for i := 0; i < 10; i++ {
+				if v, _ := conf.HashServer("hall", tt.uid); v.ID != first.ID {
+					t.Fatalf("HashServer(%q) = %s, want %s", tt.uid, v.ID, first.ID)
+				}
+			}
+			if v, _ := testConfig("hall-003", "hall-001", "hall-002").HashServer("hall", tt.uid); v.ID != first.ID {
+				t.Fatalf("HashServer(%q) after reload = %s, want %s", tt.uid, v.ID, first.ID)
+			}
+		})
+	}
+}
+
+// TestHashServerAdd 增加一台服务器 只有少量uid换服务器 并且都换到新服务器
+func TestHashServerAdd(t *testing.T) {
+	const n = 10000
+	before := testConfig("hall-001", "hall-002", "hall-003")
+	after := testConfig("hall-001", "hall-002", "hall-003", "hall-004")
+	moved := 0
+	for i := 0; i < n; i++ {
+		uid := strconv.Itoa(100000 + i)
+		a, _ := before.HashServer("hall", uid)
+		b, _ := after.HashServer("hall", uid)
+		if a.ID == b.ID {
+			continue
+		}
+		if b.ID != "hall-004" {
+			t.Fatalf("uid %s moved from %s to %s", uid, a.ID, b.ID)
+		}
+		moved++
+	}
+	// 理想情况是1/4 虚拟节点不完全均匀 留出余量
+	if moved == 0 || moved > n*2/5 {
+		t.Fatalf("moved %d of %d uids", moved, n)
+	}
+}
+
+func TestRoundRobinServer(t *testing.T) {
+	conf := testConfig("hall-001", "hall-002", "hall-003")
+	roundRobinCounters.Delete("hall")
+	t.Cleanup(func() { roundRobinCounters.Delete("hall") })
+	want := []string{"hall-001", "hall-002", "hall-003", "hall-001", "hall-002"}
+	for i, id := range want {
+		v, ok := conf.RoundRobinServer("hall")
+		if !ok || v.ID != id {
+			t.Fatalf("RoundRobinServer() %d = %v, want %s", i, v, id)
+		}
+	}
+}
+
+func TestSelectServer(t *testing.T) {
+	conf := testConfig("hall-001", "hall-002")
+	tests := []struct {
+		name       string
+		serverType string
+		strategy   SelectStrategy
+		want       bool
+	}{
+		{"random", "hall", SelectRandom, true},
+		{"empty strategy", "hall", "", true},
+		{"round robin", "hall", SelectRoundRobin, true},
+		{"hash", "hall", SelectHash, true},
+		{"random no server", "game", SelectRandom, false},
+		{"round robin no server", "game", SelectRoundRobin, false},
+		{"hash no server", "game", SelectHash, false},
+		{"empty server type", "", SelectHash, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := conf.SelectServer(tt.serverType, tt.strategy, "10001")
+			if ok != tt.want {
+				t.Fatalf("SelectServer() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && v.ServerType != tt.serverType {
+				t.Fatalf("SelectServer() = %s of %s", v.ID, v.ServerType)
+			}
+		})
+	}
+}
+
+func TestLoadUnknownServerSelect(t *testing.T) {
+	dir := writeConfigDir(t, `{}`)
+	servers := `{
+  "nats": {"url": "nats://localhost:4222"},
+  "connector": [{"id": "connector001", "clientPort": 12000, "serverSelect": "leastConn"}],
+  "servers": [{"id": "hall-001", "serverType": "hall"}]
+}`
+	if err := os.WriteFile(filepath.Join(dir, serversFile), []byte(servers), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Fatal("Load() with unknown serverSelect err = nil")
+	}
+	if _, problems := Check(dir); len(problems) != 1 {
+		t.Fatalf("Check() problems = %v, want 1", problems)
+	}
+}
@@ -0,0 +1,72 @@
+package net
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+)
+
+// maxDecompressedSize 客户端压缩消息解压后的最大字节数
+const maxDecompressedSize = 16 * MaxPacketSize
+
+var ErrDecompressedSizeExceed = errors.New("decompressed size exceed")
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// compress gzip压缩 压缩后没有变小时返回false 调用方发送原始数据
+func compress(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 2)
+	w := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// Decompress 解压客户端发送的gzip消息体 解压后超过maxDecompressedSize返回错误
+func Decompress(data []byte) ([]byte, error) {
+	return DecompressLimit(data, maxDecompressedSize)
+}
+
+// DecompressLimit 解压后超过maxSize返回ErrDecompressedSizeExceed 客户端解压服务端数据时使用MaxBodyLen
+func DecompressLimit(data []byte, maxSize int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	res, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(res) > maxSize {
+		return nil, ErrDecompressedSizeExceed
+	}
+	return res, nil
+}
+
+// compressMessage 协商了压缩并且数据达到阈值时压缩消息体
+func (m *Manager) compressMessage(msg *Message, enabled bool) {
+	if !enabled || m.CompressThreshold <= 0 || len(msg.Data) < m.CompressThreshold {
+		return
+	}
+	if data, ok := compress(msg.Data); ok {
+		msg.Data = data
+		msg.Compressed = true
+	}
+}
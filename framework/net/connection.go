@@ -0,0 +1,255 @@
+package net
+
+import (
+	"common/logs"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	cidBase uint64 = 10000
+
+	ErrConnectionClosed = errors.New("connection closed")
+	ErrWriteQueueFull   = errors.New("write queue full")
+	ErrRequestExpired   = errors.New("request expired")
+)
+
+const (
+	// writeWait 单次写超时
+	writeWait = 10 * time.Second
+	// maxMessageSize 客户端单帧最大字节数 一帧可能包含多个包
+	maxMessageSize = 4 * MaxPacketSize
+	// defaultWriteQueueSize 没有配置时每个连接的写队列长度
+	defaultWriteQueueSize = 1024
+)
+
+// SlowConsumerPolicy 写队列满时如何处理推送
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerDrop 丢弃推送 响应等不能丢的数据仍然断开连接
+	SlowConsumerDrop SlowConsumerPolicy = "drop"
+	// SlowConsumerClose 直接断开连接
+	SlowConsumerClose SlowConsumerPolicy = "close"
+)
+
+type Connection interface {
+	GetCid() string
+	GetUid() string
+	Bind(uid string)
+	GetSession() *Session
+	SendMessage(buf []byte) error
+	SendPush(buf []byte) error
+	Kick(buf []byte)
+	Close()
+}
+
+// WsConnection 一个websocket客户端连接 读写各一个goroutine
+type WsConnection struct {
+	Cid       string
+	Conn      *websocket.Conn
+	manager   *Manager
+	ReadChan  chan *MsgPack
+	WriteChan chan []byte
+	closeChan chan struct{}
+	closeOnce sync.Once
+	// writeLock 保证关闭之后不会再有数据进入写队列 队列计数才能归零
+	writeLock sync.RWMutex
+	closed    bool
+	// lastActive 最后一次收到客户端数据的时间 UnixNano
+	lastActive int64
+	session    *Session
+}
+
+func NewWsConnection(conn *websocket.Conn, manager *Manager) *WsConnection {
+	cid := fmt.Sprintf("%s-%d", manager.ServerId, atomic.AddUint64(&cidBase, 1))
+	queueSize := manager.WriteQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultWriteQueueSize
+	}
+	return &WsConnection{
+		Cid:        cid,
+		Conn:       conn,
+		manager:    manager,
+		ReadChan:   manager.ClientReadChan,
+		WriteChan:  make(chan []byte, queueSize),
+		closeChan:  make(chan struct{}),
+		lastActive: time.Now().UnixNano(),
+		session:    NewSession(manager.ServerId, cid),
+	}
+}
+
+func (c *WsConnection) GetCid() string {
+	return c.Cid
+}
+
+func (c *WsConnection) GetUid() string {
+	return c.session.GetUid()
+}
+
+// Bind entry成功后绑定uid
+func (c *WsConnection) Bind(uid string) {
+	c.session.SetUid(uid)
+}
+
+func (c *WsConnection) GetSession() *Session {
+	return c.session
+}
+
+func (c *WsConnection) Run() {
+	go c.readMessage()
+	go c.writeMessage()
+}
+
+// SendMessage 响应、握手等不能丢弃的数据 写队列满时说明客户端读得太慢 直接断开
+func (c *WsConnection) SendMessage(buf []byte) error {
+	err := c.enqueue(buf)
+	if err == ErrWriteQueueFull {
+		c.slowConsumer()
+	}
+	return err
+}
+
+// SendPush 推送 写队列满时按SlowConsumer策略丢弃或者断开
+func (c *WsConnection) SendPush(buf []byte) error {
+	err := c.enqueue(buf)
+	if err == ErrWriteQueueFull {
+		if c.manager.SlowConsumer == SlowConsumerClose {
+			c.slowConsumer()
+		} else {
+			writeQueueDropped.Add(1)
+		}
+	}
+	return err
+}
+
+// Kick 踢下线包写出后断开连接 写队列满时直接断开
+func (c *WsConnection) Kick(buf []byte) {
+	if c.enqueue(buf) != nil {
+		c.manager.removeClient(c)
+		return
+	}
+	// nil 表示写完前面的数据后关闭连接
+	if c.enqueue(nil) != nil {
+		c.manager.removeClient(c)
+	}
+}
+
+// enqueue 写队列不阻塞 满了返回ErrWriteQueueFull
+func (c *WsConnection) enqueue(buf []byte) error {
+	c.writeLock.RLock()
+	defer c.writeLock.RUnlock()
+	if c.closed {
+		return ErrConnectionClosed
+	}
+	select {
+	case c.WriteChan <- buf:
+		writeQueueDepth.Add(1)
+		return nil
+	default:
+		return ErrWriteQueueFull
+	}
+}
+
+func (c *WsConnection) slowConsumer() {
+	logs.Warn("client[%s] write queue full, close slow consumer", c.Cid)
+	slowConsumerClosed.Add(1)
+	c.manager.removeClient(c)
+}
+
+// Close 可重复调用 只会关闭一次
+func (c *WsConnection) Close() {
+	c.closeOnce.Do(func() {
+		c.writeLock.Lock()
+		c.closed = true
+		c.writeLock.Unlock()
+		close(c.closeChan)
+		if err := c.Conn.Close(); err != nil {
+			logs.Error("client[%s] close conn err:%v", c.Cid, err)
+		}
+	})
+}
+
+func (c *WsConnection) readMessage() {
+	defer c.manager.removeClient(c)
+	c.Conn.SetReadLimit(maxMessageSize)
+	for {
+		messageType, message, err := c.Conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logs.Error("client[%s] read message err:%v", c.Cid, err)
+			}
+			return
+		}
+		atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+		bytesIn.Add(int64(len(message)))
+		// 客户端只发送二进制消息
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		select {
+		case c.ReadChan <- &MsgPack{Cid: c.Cid, Body: message}:
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// idleTimeout 超过两个心跳间隔没有收到任何数据
+func (c *WsConnection) idleTimeout() bool {
+	last := time.Unix(0, atomic.LoadInt64(&c.lastActive))
+	return time.Since(last) > 2*c.manager.HeartTime
+}
+
+// drainWriteQueue 连接关闭后丢弃未写出的数据
+func (c *WsConnection) drainWriteQueue() {
+	for {
+		select {
+		case <-c.WriteChan:
+			writeQueueDepth.Add(-1)
+		default:
+			return
+		}
+	}
+}
+
+func (c *WsConnection) writeMessage() {
+	// removeClient先执行 关闭后再清空写队列
+	defer c.drainWriteQueue()
+	defer c.manager.removeClient(c)
+	var heartCheck <-chan time.Time
+	if c.manager.HeartTime > 0 {
+		ticker := time.NewTicker(c.manager.HeartTime)
+		defer ticker.Stop()
+		heartCheck = ticker.C
+	}
+	for {
+		select {
+		case <-heartCheck:
+			if c.idleTimeout() {
+				logs.Warn("client[%s] heartbeat timeout, close", c.Cid)
+				return
+			}
+		case message := <-c.WriteChan:
+			writeQueueDepth.Add(-1)
+			if message == nil {
+				return
+			}
+			if err := c.Conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+				logs.Error("client[%s] set write deadline err:%v", c.Cid, err)
+				return
+			}
+			if err := c.Conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+				logs.Error("client[%s] write message err:%v", c.Cid, err)
+				return
+			}
+			bytesOut.Add(int64(len(message)))
+		case <-c.closeChan:
+			return
+		}
+	}
+}
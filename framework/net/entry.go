@@ -0,0 +1,131 @@
+package net
+
+import (
+	"common/biz"
+	"common/logs"
+	"encoding/json"
+	"framework/waError"
+	"time"
+)
+
+// EntryRoute 客户端携带gateway下发的token进入connector 处理函数由connector注册
+// 处理成功后session上绑定了uid 在这之前连接只允许握手、心跳和entry
+const EntryRoute = "connector.entryHandler.entry"
+
+// defaultAuthTimeout 建立连接后需要在这个时间内完成entry 否则踢下线
+const defaultAuthTimeout = 10 * time.Second
+
+// 踢下线的原因 随kick包发给客户端
+const (
+	KickReasonAuthTimeout    = "auth timeout"
+	KickReasonDuplicateLogin = "duplicate login"
+	KickReasonRateLimit      = "request too frequent"
+	KickReasonServerRestart  = "server restarting"
+)
+
+// Result 响应给客户端的数据 格式与gateway保持一致
+type Result struct {
+	Code int `json:"code"`
+	Msg  any `json:"msg"`
+}
+
+// KickBody 踢下线包体
+type KickBody struct {
+	Reason string `json:"reason"`
+}
+
+// checkAuth 到时间还未entry的连接踢下线 entry成功或者连接关闭时取消
+func (m *Manager) checkAuth(c Connection) {
+	timeout := m.AuthTimeout
+	if timeout <= 0 {
+		timeout = defaultAuthTimeout
+	}
+	cid := c.GetCid()
+	m.Lock()
+	defer m.Unlock()
+	if cur, ok := m.clients[cid]; !ok || cur != c {
+		// 连接已经关闭
+		return
+	}
+	m.authTimers[cid] = time.AfterFunc(timeout, func() {
+		m.Lock()
+		delete(m.authTimers, cid)
+		cur, ok := m.clients[cid]
+		m.Unlock()
+		if !ok || cur != c || c.GetUid() != "" {
+			return
+		}
+		logs.Warn("client[%s] not entry in %v, kick", cid, timeout)
+		m.Kick(c, KickReasonAuthTimeout)
+	})
+}
+
+// stopAuth 需要持有锁
+func (m *Manager) stopAuth(cid string) {
+	if t, ok := m.authTimers[cid]; ok {
+		t.Stop()
+		delete(m.authTimers, cid)
+	}
+}
+
+// Kick 发送踢下线包 写出后断开连接
+func (m *Manager) Kick(c Connection, reason string) {
+	kicks.Add(reason, 1)
+	data, _ := json.Marshal(&KickBody{Reason: reason})
+	buf, err := Encode(Kick, data)
+	if err != nil {
+		logs.Error("client[%s] encode kick packet err:%v", c.GetCid(), err)
+		m.closeClient(c)
+		return
+	}
+	c.Kick(buf)
+}
+
+// NewResult 处理函数的返回值转换成响应
+func NewResult(data any, err *waError.Error) *Result {
+	if err != nil {
+		return &Result{
+			Code: err.Code,
+			Msg:  err.Err.Error(),
+		}
+	}
+	return &Result{
+		Code: biz.OK,
+		Msg:  data,
+	}
+}
+
+// MarshalResult 按客户端的序列化方式编码响应 返回值不能编码时响应biz.Fail
+func MarshalResult(s Serializer, data any, err *waError.Error) ([]byte, error) {
+	res, marshalErr := s.Marshal(NewResult(data, err))
+	if marshalErr == nil {
+		return res, nil
+	}
+	logs.Error("marshal result with %s err:%v", s.Name(), marshalErr)
+	return s.Marshal(NewResult(nil, biz.Fail))
+}
+
+func (m *Manager) responseError(c Connection, msg *Message, err *waError.Error) error {
+	return m.response(c, msg, nil, err)
+}
+
+func (m *Manager) responseData(c Connection, msg *Message, data any) error {
+	return m.response(c, msg, data, nil)
+}
+
+// response notify不需要响应
+func (m *Manager) response(c Connection, msg *Message, res any, bizErr *waError.Error) error {
+	if msg.Type != Request {
+		return nil
+	}
+	messagesOut.Add(knownMetricRoute(msg.Route), 1)
+	data, err := MarshalResult(c.GetSession().Serializer(), res, bizErr)
+	if err != nil {
+		return err
+	}
+	return m.sendMessage(c, &Message{
+		Type: Response,
+		ID:   msg.ID,
+		Data: data,
+	})
+}
@@ -0,0 +1,114 @@
+package net
+
+import (
+	"common/biz"
+	"common/logs"
+	"errors"
+	"fmt"
+	"framework/waError"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidRoute = errors.New("invalid route")
+
+// HandlerFunc 路由处理函数 返回的数据作为响应发给客户端 notify的返回值会被忽略
+type HandlerFunc func(session *Session, msg *Message) (any, *waError.Error)
+
+// Typed 请求数据按session的序列化方式解析成T后调用fn 解析失败响应biz.RequestDataError
+// 使用protobuf的客户端要求*T是protobuf消息
+func Typed[T any](fn func(session *Session, req *T) (any, *waError.Error)) HandlerFunc {
+	return func(session *Session, msg *Message) (any, *waError.Error) {
+		req := new(T)
+		if len(msg.Data) > 0 {
+			if err := session.Serializer().Unmarshal(msg.Data, req); err != nil {
+				logs.Warn("client[%s] route:%s unmarshal request err:%v", session.GetCid(), msg.Route, err)
+				return nil, biz.RequestDataError
+			}
+		}
+		return fn(session, req)
+	}
+}
+
+// Route serverType.handler.method 例如 connector.entryHandler.entry
+type Route struct {
+	ServerType string
+	Handler    string
+	Method     string
+}
+
+func ParseRoute(route string) (*Route, error) {
+	parts := strings.Split(route, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRoute, route)
+	}
+	for _, v := range parts {
+		if v == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRoute, route)
+		}
+	}
+	return &Route{
+		ServerType: parts[0],
+		Handler:    parts[1],
+		Method:     parts[2],
+	}, nil
+}
+
+func (r *Route) String() string {
+	return r.ServerType + "." + r.Handler + "." + r.Method
+}
+
+// HandlerRegistry 路由到处理函数的注册表 connector和后端服务器共用
+type HandlerRegistry struct {
+	sync.RWMutex
+	handlers map[string]HandlerFunc
+}
+
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Register 注册路由 路由格式不对或者重复注册返回错误
+func (r *HandlerRegistry) Register(route string, handler HandlerFunc) error {
+	if _, err := ParseRoute(route); err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.handlers[route]; ok {
+		return fmt.Errorf("route %s already registered", route)
+	}
+	r.handlers[route] = handler
+	return nil
+}
+
+func (r *HandlerRegistry) Get(route string) (HandlerFunc, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	h, ok := r.handlers[route]
+	return h, ok
+}
+
+// Routes 已注册的路由 按字典序排列
+func (r *HandlerRegistry) Routes() []string {
+	r.RLock()
+	defer r.RUnlock()
+	routes := make([]string, 0, len(r.handlers))
+	for k := range r.handlers {
+		routes = append(routes, k)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// Dispatch 调用路由对应的处理函数 未注册的路由返回biz.RouteNotFound
+func (r *HandlerRegistry) Dispatch(session *Session, msg *Message) (any, *waError.Error) {
+	handler, ok := r.Get(msg.Route)
+	if !ok {
+		return nil, biz.RouteNotFound
+	}
+	return handler(session, msg)
+}
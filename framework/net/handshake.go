@@ -0,0 +1,39 @@
+package net
+
+import "encoding/json"
+
+const (
+	handshakeOk   = 200
+	handshakeFail = 500
+)
+
+// HandshakeRequest 客户端握手包体
+type HandshakeRequest struct {
+	Sys  HandshakeSys   `json:"sys"`
+	User map[string]any `json:"user"`
+}
+
+type HandshakeSys struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	// Serializer 消息体序列化方式 json或者protobuf 不传使用json
+	Serializer string `json:"serializer"`
+	// Compress 客户端支持gzip压缩的消息体
+	Compress bool `json:"compress"`
+}
+
+// HandshakeResponse 服务端回应的握手参数
+type HandshakeResponse struct {
+	Code int                  `json:"code"`
+	Sys  HandshakeResponseSys `json:"sys"`
+}
+
+type HandshakeResponseSys struct {
+	Heartbeat  int               `json:"heartbeat,omitempty"` // 心跳间隔 秒
+	Dict       map[string]uint16 `json:"dict,omitempty"`
+	Serializer string            `json:"serializer,omitempty"`
+	// Compress 服务端会压缩超过阈值的消息体 客户端也可以发送压缩的消息
+	Compress bool `json:"compress,omitempty"`
+	// Config gameConfig.json中可以下发给客户端的配置项
+	Config json.RawMessage `json:"config,omitempty"`
+}
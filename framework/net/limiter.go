@@ -0,0 +1,86 @@
+package net
+
+import (
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// violationWindow 统计超限次数的时间窗口
+const violationWindow = time.Minute
+
+// Limit 令牌桶 Rate每秒放入的令牌数 Burst桶容量
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig 连接和路由的限流配置 Rate为0表示不限制
+type RateLimitConfig struct {
+	Conn   Limit
+	Routes map[string]Limit
+	// MaxViolations 一个时间窗口内超限次数达到后踢下线 0表示不踢
+	MaxViolations int
+}
+
+// limiter 每个连接一个 按连接和路由分别限流
+type limiter struct {
+	sync.Mutex
+	conf        *RateLimitConfig
+	conn        *rate.Limiter
+	routes      map[string]*rate.Limiter
+	violations  int
+	windowStart time.Time
+}
+
+func newLimiter(conf *RateLimitConfig) *limiter {
+	l := &limiter{
+		conf:   conf,
+		routes: make(map[string]*rate.Limiter),
+	}
+	if conf.Conn.Rate > 0 {
+		l.conn = rate.NewLimiter(rate.Limit(conf.Conn.Rate), burst(conf.Conn))
+	}
+	return l
+}
+
+// allow 连接和路由都有令牌才放行
+func (l *limiter) allow(route string) bool {
+	l.Lock()
+	defer l.Unlock()
+	if limit, ok := l.conf.Routes[route]; ok && limit.Rate > 0 {
+		routeLimiter, ok := l.routes[route]
+		if !ok {
+			routeLimiter = rate.NewLimiter(rate.Limit(limit.Rate), burst(limit))
+			l.routes[route] = routeLimiter
+		}
+		if !routeLimiter.Allow() {
+			return false
+		}
+	}
+	return l.conn == nil || l.conn.Allow()
+}
+
+// violate 记录一次超限 返回是否达到踢下线的次数
+func (l *limiter) violate() bool {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	if now.Sub(l.windowStart) > violationWindow {
+		l.windowStart = now
+		l.violations = 0
+	}
+	l.violations++
+	return l.conf.MaxViolations > 0 && l.violations >= l.conf.MaxViolations
+}
+
+// burst 没有配置时桶容量等于每秒速率
+func burst(limit Limit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	if limit.Rate < 1 {
+		return 1
+	}
+	return int(limit.Rate)
+}
@@ -0,0 +1,86 @@
+package net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	conf := &RateLimitConfig{
+		// 速率很低 测试过程中不会补充令牌
+		Conn: Limit{Rate: 0.001, Burst: 3},
+		Routes: map[string]Limit{
+			"hall.userHandler.chat": {Rate: 0.001, Burst: 1},
+			"hall.userHandler.free": {Rate: 0},
+		},
+	}
+	tests := []struct {
+		name  string
+		route string
+		want  bool
+	}{
+		{"route bucket", "hall.userHandler.chat", true}, // 同时消耗连接桶
+		{"route bucket empty", "hall.userHandler.chat", false},
+		// 路由桶拒绝时不消耗连接桶
+		{"conn bucket", "hall.userHandler.other", true},
+		{"route rate 0 only conn bucket", "hall.userHandler.free", true},
+		{"conn bucket empty", "hall.userHandler.other", false},
+		{"conn bucket empty for free route", "hall.userHandler.free", false},
+	}
+	l := newLimiter(conf)
+	for _, tt := range tests {
+		if got := l.allow(tt.route); got != tt.want {
+			t.Fatalf("%s: allow(%s) = %v, want %v", tt.name, tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterNoLimit(t *testing.T) {
+	l := newLimiter(&RateLimitConfig{})
+	for i := 0; i < 100; i++ {
+		if !l.allow("hall.userHandler.chat") {
+			t.Fatalf("allow %d = false without limit", i)
+		}
+	}
+}
+
+func TestLimiterViolate(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxViolations int
+		violations    int
+		windowExpired bool
+		want          bool
+	}{
+		{"below max", 3, 1, false, false},
+		{"reach max", 3, 2, false, true},
+		{"window expired reset", 3, 2, true, false},
+		{"no kick", 0, 100, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiter(&RateLimitConfig{MaxViolations: tt.maxViolations})
+			l.windowStart = time.Now()
+			l.violations = tt.violations
+			if tt.windowExpired {
+				l.windowStart = time.Now().Add(-violationWindow - time.Second)
+			}
+			if got := l.violate(); got != tt.want {
+				t.Fatalf("violate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAllowClosedClient 连接关闭后才处理的消息不会重新创建限流器
+func TestAllowClosedClient(t *testing.T) {
+	m := NewManager()
+	m.RateLimit = &RateLimitConfig{Conn: Limit{Rate: 100}}
+	c := &WsConnection{Cid: "closed", session: NewSession("connector001", "closed")}
+	if m.allow(c, "hall.userHandler.chat") {
+		t.Fatal("allow() = true for closed client")
+	}
+	if len(m.limiters) != 0 {
+		t.Fatalf("limiters = %d, want 0", len(m.limiters))
+	}
+}
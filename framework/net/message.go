@@ -0,0 +1,239 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+)
+
+// MessageType 消息类型 与pomelo协议保持一致
+type MessageType byte
+
+const (
+	Request  MessageType = 0x00
+	Notify   MessageType = 0x01
+	Response MessageType = 0x02
+	Push     MessageType = 0x03
+)
+
+const (
+	// msgRouteCompressMask flag最低位 路由是否压缩成字典编码
+	msgRouteCompressMask = 0x01
+	// msgTypeMask flag的1~3位 消息类型
+	msgTypeMask = 0x07
+	// msgCompressGzipMask flag第4位 消息体是否gzip压缩
+	msgCompressGzipMask = 0x10
+	// msgRouteCodeBytes 压缩后的路由占2字节
+	msgRouteCodeBytes = 2
+	// msgRouteMaxLen 未压缩的路由用1字节表示长度
+	msgRouteMaxLen = 255
+	msgHeadLength  = 1
+)
+
+var (
+	ErrWrongMessageType  = errors.New("wrong message type")
+	ErrInvalidMessage    = errors.New("invalid message")
+	ErrRouteInfoNotFound = errors.New("route info not found in dictionary")
+	ErrRouteTooLong      = errors.New("route too long")
+	ErrRouteDictFull     = errors.New("route dictionary full")
+)
+
+// Message 数据包的包体
+// flag(1) + id(变长 仅request/response) + route(仅request/notify/push) + data
+type Message struct {
+	Type  MessageType
+	ID    uint
+	Route string
+	Data  []byte
+	// Compressed Data是gzip压缩过的 编解码时不会自动压缩解压
+	Compressed bool
+}
+
+func (t MessageType) valid() bool {
+	return t >= Request && t <= Push
+}
+
+func (m *Message) hasID() bool {
+	return m.Type == Request || m.Type == Response
+}
+
+func (m *Message) hasRoute() bool {
+	return m.Type == Request || m.Type == Notify || m.Type == Push
+}
+
+// MessageEncode 编码消息 路由在字典中时自动压缩
+func MessageEncode(m *Message, dict *RouteDict) ([]byte, error) {
+	if !m.Type.valid() {
+		return nil, ErrWrongMessageType
+	}
+	buf := make([]byte, 0, msgHeadLength+len(m.Route)+len(m.Data)+4)
+	flag := byte(m.Type) << 1
+	code, compressed := dict.Code(m.Route)
+	if compressed && m.hasRoute() {
+		flag |= msgRouteCompressMask
+	}
+	if m.Compressed {
+		flag |= msgCompressGzipMask
+	}
+	buf = append(buf, flag)
+	if m.hasID() {
+		buf = appendVarint(buf, m.ID)
+	}
+	if m.hasRoute() {
+		if compressed {
+			buf = binary.BigEndian.AppendUint16(buf, code)
+		} else {
+			if len(m.Route) > msgRouteMaxLen {
+				return nil, ErrRouteTooLong
+			}
+			buf = append(buf, byte(len(m.Route)))
+			buf = append(buf, m.Route...)
+		}
+	}
+	buf = append(buf, m.Data...)
+	return buf, nil
+}
+
+// MessageDecode 解码消息 压缩的路由通过字典还原
+func MessageDecode(data []byte, dict *RouteDict) (*Message, error) {
+	if len(data) < msgHeadLength {
+		return nil, ErrInvalidMessage
+	}
+	m := &Message{}
+	flag := data[0]
+	offset := msgHeadLength
+	m.Type = MessageType((flag >> 1) & msgTypeMask)
+	if !m.Type.valid() {
+		return nil, ErrWrongMessageType
+	}
+	m.Compressed = flag&msgCompressGzipMask != 0
+	if m.hasID() {
+		id, n := readVarint(data[offset:])
+		if n <= 0 {
+			return nil, ErrInvalidMessage
+		}
+		m.ID = id
+		offset += n
+	}
+	if m.hasRoute() {
+		if flag&msgRouteCompressMask == 1 {
+			if len(data) < offset+msgRouteCodeBytes {
+				return nil, ErrInvalidMessage
+			}
+			code := binary.BigEndian.Uint16(data[offset:])
+			route, ok := dict.Route(code)
+			if !ok {
+				return nil, ErrRouteInfoNotFound
+			}
+			m.Route = route
+			offset += msgRouteCodeBytes
+		} else {
+			if len(data) < offset+1 {
+				return nil, ErrInvalidMessage
+			}
+			length := int(data[offset])
+			offset++
+			if len(data) < offset+length {
+				return nil, ErrInvalidMessage
+			}
+			m.Route = string(data[offset : offset+length])
+			offset += length
+		}
+	}
+	m.Data = data[offset:]
+	return m, nil
+}
+
+// appendVarint id按7位一组 低位在前 最高位表示后面还有数据
+func appendVarint(buf []byte, n uint) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n != 0 {
+			buf = append(buf, b|0x80)
+		} else {
+			return append(buf, b)
+		}
+	}
+}
+
+func readVarint(data []byte) (uint, int) {
+	var n uint
+	for i, b := range data {
+		if i >= 10 {
+			return 0, -1
+		}
+		n |= uint(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return n, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// RouteDict 路由字典 握手时下发给客户端 双方用2字节编码代替路由字符串
+type RouteDict struct {
+	sync.RWMutex
+	routes map[string]uint16
+	codes  map[uint16]string
+}
+
+func NewRouteDict() *RouteDict {
+	return &RouteDict{
+		routes: make(map[string]uint16),
+		codes:  make(map[uint16]string),
+	}
+}
+
+// Add 路由不存在时分配下一个编码 编码从1开始 编码用完时返回ErrRouteDictFull
+func (d *RouteDict) Add(routes ...string) error {
+	d.Lock()
+	defer d.Unlock()
+	for _, route := range routes {
+		if _, ok := d.routes[route]; ok {
+			continue
+		}
+		if len(d.routes) >= math.MaxUint16 {
+			return ErrRouteDictFull
+		}
+		code := uint16(len(d.routes) + 1)
+		d.routes[route] = code
+		d.codes[code] = route
+	}
+	return nil
+}
+
+func (d *RouteDict) Code(route string) (uint16, bool) {
+	if d == nil {
+		return 0, false
+	}
+	d.RLock()
+	defer d.RUnlock()
+	code, ok := d.routes[route]
+	return code, ok
+}
+
+func (d *RouteDict) Route(code uint16) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	d.RLock()
+	defer d.RUnlock()
+	route, ok := d.codes[code]
+	return route, ok
+}
+
+// Dict 握手下发的字典 route -> code
+func (d *RouteDict) Dict() map[string]uint16 {
+	if d == nil {
+		return nil
+	}
+	d.RLock()
+	defer d.RUnlock()
+	dict := make(map[string]uint16, len(d.routes))
+	for k, v := range d.routes {
+		dict[k] = v
+	}
+	return dict
+}
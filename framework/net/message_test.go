@@ -0,0 +1,129 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testDict() *RouteDict {
+	d := NewRouteDict()
+	_ = d.Add("connector.entryHandler.entry", "hall.userHandler.onPush")
+	return d
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      *Message
+		wantFlag byte
+		wantLen  int
+	}{
+		{"request", &Message{Type: Request, ID: 1, Route: "hall.userHandler.info", Data: []byte("{}")}, 0x00, 1 + 1 + 1 + 21 + 2},
+		// id 300 需要两个字节
+		{"request varint id", &Message{Type: Request, ID: 300, Route: "hall.userHandler.info", Data: []byte("{}")}, 0x00, 1 + 2 + 1 + 21 + 2},
+		{"request max id", &Message{Type: Request, ID: math.MaxUint32, Route: "a.b.c"}, 0x00, 1 + 5 + 1 + 5},
+		{"notify", &Message{Type: Notify, Route: "hall.userHandler.info", Data: []byte("{}")}, 0x02, 1 + 1 + 21 + 2},
+		{"response", &Message{Type: Response, ID: 7, Data: []byte("{}")}, 0x04, 1 + 1 + 2},
+		{"push", &Message{Type: Push, Route: "game.roomHandler.onEnter", Data: []byte("{}")}, 0x06, 1 + 1 + 24 + 2},
+		{"dict route", &Message{Type: Request, ID: 1, Route: "connector.entryHandler.entry", Data: []byte("{}")}, 0x01, 1 + 1 + 2 + 2},
+		{"dict push route", &Message{Type: Push, Route: "hall.userHandler.onPush"}, 0x07, 1 + 2},
+		{"gzip", &Message{Type: Push, Route: "hall.userHandler.onPush", Data: []byte{0x1f, 0x8b}, Compressed: true}, 0x17, 1 + 2 + 2},
+		{"empty route", &Message{Type: Notify, Data: []byte("{}")}, 0x02, 1 + 1 + 2},
+		{"max route len", &Message{Type: Notify, Route: strings.Repeat("r", msgRouteMaxLen)}, 0x02, 1 + 1 + msgRouteMaxLen},
+	}
+	dict := testDict()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MessageEncode(tt.msg, dict)
+			if err != nil {
+				t.Fatalf("MessageEncode() err = %v", err)
+			}
+			if data[0] != tt.wantFlag || len(data) != tt.wantLen {
+				t.Fatalf("MessageEncode() flag = %#x len = %d, want %#x %d", data[0], len(data), tt.wantFlag, tt.wantLen)
+			}
+			got, err := MessageDecode(data, dict)
+			if err != nil {
+				t.Fatalf("MessageDecode() err = %v", err)
+			}
+			if got.Type != tt.msg.Type || got.ID != tt.msg.ID || got.Route != tt.msg.Route ||
+				got.Compressed != tt.msg.Compressed || !bytes.Equal(got.Data, tt.msg.Data) {
+				t.Fatalf("MessageDecode() = %+v, want %+v", got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestMessageEncodeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     *Message
+		wantErr error
+	}{
+		{"route too long", &Message{Type: Notify, Route: strings.Repeat("r", msgRouteMaxLen+1)}, ErrRouteTooLong},
+		{"bad type", &Message{Type: MessageType(4)}, ErrWrongMessageType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := MessageEncode(tt.msg, nil); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MessageEncode() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageDecodeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"empty", nil, ErrInvalidMessage},
+		{"bad type", []byte{0x0a}, ErrWrongMessageType},
+		{"max bad type", []byte{0x0e}, ErrWrongMessageType},
+		{"missing id", []byte{0x00}, ErrInvalidMessage},
+		{"truncated id", []byte{0x00, 0x80}, ErrInvalidMessage},
+		{"id too long", append(bytes.Repeat([]byte{0x80}, 10), 0x01), ErrInvalidMessage},
+		{"missing route length", []byte{0x02}, ErrInvalidMessage},
+		{"truncated route", []byte{0x02, 0x05, 'a', 'b'}, ErrInvalidMessage},
+		{"truncated route code", []byte{0x03, 0x00}, ErrInvalidMessage},
+		{"unknown dict code", []byte{0x03, 0x00, 0x09}, ErrRouteInfoNotFound},
+		{"dict code 0", []byte{0x03, 0x00, 0x00}, ErrRouteInfoNotFound},
+	}
+	dict := testDict()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := MessageDecode(tt.data, dict); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MessageDecode() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteDictFull(t *testing.T) {
+	d := NewRouteDict()
+	routes := make([]string, math.MaxUint16)
+	for i := range routes {
+		routes[i] = "hall.h.r" + strconv.Itoa(i)
+	}
+	if err := d.Add(routes...); err != nil {
+		t.Fatalf("Add() err = %v", err)
+	}
+	if err := d.Add("hall.h.overflow"); !errors.Is(err, ErrRouteDictFull) {
+		t.Fatalf("Add() err = %v, want ErrRouteDictFull", err)
+	}
+	// 已有的编码不能被覆盖
+	if route, _ := d.Route(1); route != routes[0] {
+		t.Fatalf("Route(1) = %s, want %s", route, routes[0])
+	}
+	if _, ok := d.Code("hall.h.overflow"); ok {
+		t.Fatal("overflow route added")
+	}
+	// 已存在的路由不需要新编码
+	if err := d.Add(routes[1]); err != nil {
+		t.Fatalf("Add() existing route err = %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package net
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// maxMetricRoutes 按路由统计的路由数上限 超过后计入otherRoute 防止客户端乱发路由撑爆指标
+const (
+	maxMetricRoutes = 1024
+	otherRoute      = "other"
+)
+
+// 通过 common/metrics 暴露在 /debug/vars
+var (
+	// writeQueueDepth 所有连接写队列中待写出的数据条数
+	writeQueueDepth = expvar.NewInt("connector_write_queue_depth")
+	// writeQueueDropped 写队列满被丢弃的推送条数
+	writeQueueDropped = expvar.NewInt("connector_write_queue_dropped")
+	// slowConsumerClosed 因为写队列满被断开的连接数
+	slowConsumerClosed = expvar.NewInt("connector_slow_consumer_closed")
+	// connections 当前websocket连接数
+	connections = expvar.NewInt("connector_connections")
+	// sessions 当前已entry的用户数 即在线人数
+	sessions = expvar.NewInt("connector_sessions")
+	// messagesIn 客户端发来的request/notify 按路由统计
+	messagesIn = expvar.NewMap("connector_messages_in")
+	// messagesOut 发给客户端的response/push 按路由统计
+	messagesOut = expvar.NewMap("connector_messages_out")
+	// bytesIn bytesOut websocket收发的字节数 包含包头
+	bytesIn  = expvar.NewInt("connector_bytes_in")
+	bytesOut = expvar.NewInt("connector_bytes_out")
+	// kicks 踢下线次数 按原因统计
+	kicks = expvar.NewMap("connector_kicks")
+)
+
+var (
+	metricRoutes     sync.Map
+	metricRouteCount int64
+)
+
+// metricRoute 已统计的路由原样返回 新路由超过上限时返回otherRoute
+// 只用于已经校验过的路由 客户端发来的路由要在认证并且确认会处理之后才能加入
+func metricRoute(route string) string {
+	if _, ok := metricRoutes.Load(route); ok {
+		return route
+	}
+	if atomic.AddInt64(&metricRouteCount, 1) > maxMetricRoutes {
+		atomic.AddInt64(&metricRouteCount, -1)
+		return otherRoute
+	}
+	if _, loaded := metricRoutes.LoadOrStore(route, struct{}{}); loaded {
+		atomic.AddInt64(&metricRouteCount, -1)
+	}
+	return route
+}
+
+// knownMetricRoute 只查找不加入 没有统计过的路由返回otherRoute 用于响应 响应的路由来自客户端
+func knownMetricRoute(route string) string {
+	if _, ok := metricRoutes.Load(route); ok {
+		return route
+	}
+	return otherRoute
+}
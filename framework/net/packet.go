@@ -0,0 +1,104 @@
+package net
+
+import (
+	"errors"
+)
+
+// PackageType 包类型 与pomelo协议保持一致
+type PackageType byte
+
+const (
+	None         PackageType = 0x00
+	Handshake    PackageType = 0x01 // 握手 客户端发起 服务端以同类型回应握手参数
+	HandshakeAck PackageType = 0x02 // 握手确认 客户端收到握手回应后发送
+	Heartbeat    PackageType = 0x03 // 心跳
+	Data         PackageType = 0x04 // 数据
+	Kick         PackageType = 0x05 // 服务端主动断开
+)
+
+const (
+	// HeaderLen 包头 1字节类型 + 3字节长度(大端)
+	HeaderLen = 4
+	// MaxBodyLen 3字节长度能表示的最大包体 也是服务端发送的上限
+	MaxBodyLen = 1<<24 - 1
+	// MaxPacketSize 接收客户端时允许的最大包体 超过直接拒绝
+	MaxPacketSize = 64 * 1024
+)
+
+var (
+	ErrWrongPacketType  = errors.New("wrong packet type")
+	ErrPacketSizeExceed = errors.New("packet size exceed")
+	ErrIncompletePacket = errors.New("incomplete packet")
+)
+
+type Packet struct {
+	Type   PackageType
+	Length int
+	Body   []byte
+}
+
+func (t PackageType) valid() bool {
+	return t >= Handshake && t <= Kick
+}
+
+// Encode 打包 type + length + body
+func Encode(t PackageType, body []byte) ([]byte, error) {
+	if !t.valid() {
+		return nil, ErrWrongPacketType
+	}
+	if len(body) > MaxBodyLen {
+		return nil, ErrPacketSizeExceed
+	}
+	buf := make([]byte, HeaderLen+len(body))
+	buf[0] = byte(t)
+	copy(buf[1:HeaderLen], intToBytes(len(body)))
+	copy(buf[HeaderLen:], body)
+	return buf, nil
+}
+
+// Decode 拆包 一帧websocket数据中可能包含多个包
+// 包不完整、类型错误或者超过MaxPacketSize都视为非法数据
+func Decode(data []byte) ([]*Packet, error) {
+	return DecodeLimit(data, MaxPacketSize)
+}
+
+// DecodeLimit 包体超过maxSize时返回ErrPacketSizeExceed 客户端解析服务端数据时使用MaxBodyLen
+func DecodeLimit(data []byte, maxSize int) ([]*Packet, error) {
+	var packets []*Packet
+	for len(data) > 0 {
+		if len(data) < HeaderLen {
+			return nil, ErrIncompletePacket
+		}
+		t := PackageType(data[0])
+		if !t.valid() {
+			return nil, ErrWrongPacketType
+		}
+		length := bytesToInt(data[1:HeaderLen])
+		if length > maxSize {
+			return nil, ErrPacketSizeExceed
+		}
+		if len(data) < HeaderLen+length {
+			return nil, ErrIncompletePacket
+		}
+		packets = append(packets, &Packet{
+			Type:   t,
+			Length: length,
+			Body:   data[HeaderLen : HeaderLen+length],
+		})
+		data = data[HeaderLen+length:]
+	}
+	return packets, nil
+}
+
+// intToBytes 大端 3字节
+func intToBytes(n int) []byte {
+	return []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+func bytesToInt(b []byte) int {
+	result := 0
+	for _, v := range b {
+		result = result<<8 | int(v)
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     PackageType
+		body    []byte
+		want    []byte
+		wantErr error
+	}{
+		{"heartbeat", Heartbeat, nil, []byte{0x03, 0x00, 0x00, 0x00}, nil},
+		{"data", Data, []byte("abc"), []byte{0x04, 0x00, 0x00, 0x03, 'a', 'b', 'c'}, nil},
+		{"length uses three bytes", Data, make([]byte, 0x010203), nil, nil},
+		{"none type", None, nil, nil, ErrWrongPacketType},
+		{"unknown type", PackageType(0x06), nil, nil, ErrWrongPacketType},
+		{"oversized body", Data, make([]byte, MaxBodyLen+1), nil, ErrPacketSizeExceed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Encode(tt.typ, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Encode() err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.want != nil && !bytes.Equal(got, tt.want) {
+				t.Fatalf("Encode() = %v, want %v", got, tt.want)
+			}
+			if n := bytesToInt(got[1:HeaderLen]); n != len(tt.body) {
+				t.Fatalf("Encode() length = %d, want %d", n, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data, _ := Encode(Data, []byte("hello"))
+	heartbeat, _ := Encode(Heartbeat, nil)
+	tests := []struct {
+		name      string
+		data      []byte
+		wantTypes []PackageType
+		wantBody  [][]byte
+		wantErr   error
+	}{
+		{"single packet", data, []PackageType{Data}, [][]byte{[]byte("hello")}, nil},
+		{"empty body", heartbeat, []PackageType{Heartbeat}, [][]byte{{}}, nil},
+		{"multiple packets", append(append([]byte{}, heartbeat...), data...), []PackageType{Heartbeat, Data}, [][]byte{{}, []byte("hello")}, nil},
+		{"empty frame", nil, nil, nil, nil},
+		{"partial header", []byte{0x04, 0x00}, nil, nil, ErrIncompletePacket},
+		{"partial body", data[:len(data)-1], nil, nil, ErrIncompletePacket},
+		{"partial second packet", append(append([]byte{}, data...), 0x03), nil, nil, ErrIncompletePacket},
+		{"oversized packet", []byte{0x04, 0x01, 0x00, 0x01}, nil, nil, ErrPacketSizeExceed},
+		{"max size header", []byte{0x04, 0xff, 0xff, 0xff}, nil, nil, ErrPacketSizeExceed},
+		{"none type", []byte{0x00, 0x00, 0x00, 0x00}, nil, nil, ErrWrongPacketType},
+		{"unknown type", []byte{0x09, 0x00, 0x00, 0x00}, nil, nil, ErrWrongPacketType},
+		{"bad type after valid packet", append(append([]byte{}, data...), 0x07, 0x00, 0x00, 0x00), nil, nil, ErrWrongPacketType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets, err := Decode(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() err = %v, want %v", err, tt.wantErr)
+			}
+			if len(packets) != len(tt.wantTypes) {
+				t.Fatalf("Decode() got %d packets, want %d", len(packets), len(tt.wantTypes))
+			}
+			for i, p := range packets {
+				if p.Type != tt.wantTypes[i] {
+					t.Errorf("packet[%d].Type = %v, want %v", i, p.Type, tt.wantTypes[i])
+				}
+				if p.Length != len(tt.wantBody[i]) || !bytes.Equal(p.Body, tt.wantBody[i]) {
+					t.Errorf("packet[%d].Body = %q, want %q", i, p.Body, tt.wantBody[i])
+				}
+			}
+		})
+	}
+}
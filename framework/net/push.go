@@ -0,0 +1,119 @@
+package net
+
+import (
+	"common/logs"
+	"fmt"
+)
+
+// PushResult 推送结果 Delivered推送成功 Undeliverable在线但是payload中没有对应序列化方式的数据
+// 两者都不包含的uid不在线
+type PushResult struct {
+	Delivered     []string `json:"delivered"`
+	Undeliverable []string `json:"undeliverable,omitempty"`
+}
+
+// Merge 合并其他connector的推送结果
+func (r *PushResult) Merge(o *PushResult) {
+	r.Delivered = append(r.Delivered, o.Delivered...)
+	r.Undeliverable = append(r.Undeliverable, o.Undeliverable...)
+}
+
+// Offline uids中既没有推送成功也不是无法推送的uid
+func (r *PushResult) Offline(uids []string) []string {
+	reached := make(map[string]struct{}, len(r.Delivered)+len(r.Undeliverable))
+	for _, uid := range r.Delivered {
+		reached[uid] = struct{}{}
+	}
+	for _, uid := range r.Undeliverable {
+		reached[uid] = struct{}{}
+	}
+	offline := make([]string, 0)
+	for _, uid := range uids {
+		if _, ok := reached[uid]; !ok {
+			offline = append(offline, uid)
+		}
+	}
+	return offline
+}
+
+// Err 有无法推送的用户时返回UndeliverableError
+func (r *PushResult) Err(route string) error {
+	if len(r.Undeliverable) == 0 {
+		return nil
+	}
+	return &UndeliverableError{Route: route, Uids: r.Undeliverable}
+}
+
+// UndeliverableError 用户在线 但是payload不能按用户的序列化方式编码 例如payload不是proto.Message时的protobuf用户
+type UndeliverableError struct {
+	Route string
+	Uids  []string
+}
+
+func (e *UndeliverableError) Error() string {
+	return fmt.Sprintf("push route:%s undeliverable to users %v", e.Route, e.Uids)
+}
+
+// PushToUsers 推送给本connector上已entry的用户
+// 按用户的序列化方式选择payload中的数据 同一种序列化方式和压缩方式只编码一次
+func (m *Manager) PushToUsers(uids []string, route string, payload Payload) *PushResult {
+	type encoding struct {
+		serializer string
+		compress   bool
+	}
+	bufs := make(map[encoding][]byte, len(payload))
+	res := &PushResult{Delivered: make([]string, 0, len(uids))}
+	for _, uid := range uids {
+		c, ok := m.getUser(uid)
+		if !ok {
+			continue
+		}
+		session := c.GetSession()
+		name := session.Serializer().Name()
+		key := encoding{serializer: name, compress: session.Compress()}
+		buf, ok := bufs[key]
+		if !ok {
+			data, ok := payload[name]
+			if !ok {
+				logs.Warn("push to user[%s] route:%s without %s payload", uid, route, name)
+				res.Undeliverable = append(res.Undeliverable, uid)
+				continue
+			}
+			var err error
+			if buf, err = m.encodePush(route, data, key.compress); err != nil {
+				logs.Error("encode push route:%s err:%v", route, err)
+				return res
+			}
+			bufs[key] = buf
+		}
+		if err := c.SendPush(buf); err != nil {
+			logs.Warn("push to user[%s] route:%s err:%v", uid, route, err)
+			continue
+		}
+		messagesOut.Add(metricRoute(route), 1)
+		res.Delivered = append(res.Delivered, uid)
+	}
+	return res
+}
+
+// Broadcast 推送给本connector上所有已entry的用户
+func (m *Manager) Broadcast(route string, payload Payload) *PushResult {
+	m.RLock()
+	uids := make([]string, 0, len(m.users))
+	for uid := range m.users {
+		uids = append(uids, uid)
+	}
+	m.RUnlock()
+	return m.PushToUsers(uids, route, payload)
+}
+
+// encodePush 编码推送 compress为true时按阈值压缩
+func (m *Manager) encodePush(route string, data []byte, compress bool) ([]byte, error) {
+	msg := &Message{Type: Push, Route: route, Data: data}
+	m.compressMessage(msg, compress)
+	body, err := MessageEncode(msg, m.RouteDict)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(Data, body)
+}
@@ -0,0 +1,139 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"framework/net/pb"
+	"google.golang.org/protobuf/proto"
+	"sync"
+)
+
+// 内置的序列化方式 客户端握手时通过sys.serializer选择 不传使用json
+const (
+	SerializerJSON     = "json"
+	SerializerProtobuf = "protobuf"
+)
+
+var ErrNotProtoMessage = errors.New("not a protobuf message")
+
+// Serializer 客户端消息体的序列化方式 握手包、踢下线包始终是json
+type Serializer interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	serializerLock sync.RWMutex
+	serializers    = map[string]Serializer{
+		SerializerJSON:     JSONSerializer{},
+		SerializerProtobuf: ProtobufSerializer{},
+	}
+)
+
+// RegisterSerializer 注册自定义序列化方式 同名覆盖
+func RegisterSerializer(s Serializer) {
+	serializerLock.Lock()
+	defer serializerLock.Unlock()
+	serializers[s.Name()] = s
+}
+
+// GetSerializer 空名字返回json
+func GetSerializer(name string) (Serializer, bool) {
+	if name == "" {
+		name = SerializerJSON
+	}
+	serializerLock.RLock()
+	defer serializerLock.RUnlock()
+	s, ok := serializers[name]
+	return s, ok
+}
+
+func allSerializers() []Serializer {
+	serializerLock.RLock()
+	defer serializerLock.RUnlock()
+	list := make([]Serializer, 0, len(serializers))
+	for _, s := range serializers {
+		list = append(list, s)
+	}
+	return list
+}
+
+type JSONSerializer struct{}
+
+func (JSONSerializer) Name() string {
+	return SerializerJSON
+}
+
+func (JSONSerializer) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONSerializer) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtobufSerializer 只能序列化protobuf消息
+// 响应Result编码成pb.Result msg是处理函数返回的消息或者错误信息
+type ProtobufSerializer struct{}
+
+func (ProtobufSerializer) Name() string {
+	return SerializerProtobuf
+}
+
+func (s ProtobufSerializer) Marshal(v any) ([]byte, error) {
+	if res, ok := v.(*Result); ok {
+		return s.marshalResult(res)
+	}
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtobufSerializer) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (s ProtobufSerializer) marshalResult(res *Result) ([]byte, error) {
+	pbRes := &pb.Result{Code: int32(res.Code)}
+	switch msg := res.Msg.(type) {
+	case nil:
+	case string:
+		pbRes.Msg = []byte(msg)
+	default:
+		data, err := s.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		pbRes.Msg = data
+	}
+	return proto.Marshal(pbRes)
+}
+
+// Payload 序列化方式 -> 序列化后的数据 推送给多个用户时每种序列化方式只编码一次
+type Payload map[string][]byte
+
+// MarshalPayload 用所有序列化方式编码 不支持的跳过 全部失败时返回错误
+func MarshalPayload(v any) (Payload, error) {
+	payload := make(Payload)
+	var lastErr error
+	for _, s := range allSerializers() {
+		data, err := s.Marshal(v)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		payload[s.Name()] = data
+	}
+	if len(payload) == 0 {
+		return nil, lastErr
+	}
+	return payload, nil
+}
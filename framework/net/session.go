@@ -0,0 +1,213 @@
+package net
+
+import (
+	"sync"
+)
+
+// Session 用户在connector连接上的上下文 随连接创建 连接关闭后销毁
+// 转发请求时以SessionData的形式带给后端服务器 后端的修改随响应推回connector
+type Session struct {
+	sync.RWMutex
+	frontendId string // 客户端连接所在的connector
+	cid        string
+	uid        string
+	serializer string            // 握手时协商的消息体序列化方式
+	compress   bool              // 握手时协商的消息体压缩 只在connector上使用
+	servers    map[string]string // serverType -> serverId 用户当前所在的hall/game服务器
+	data       map[string]any
+	track      bool         // 后端还原的session需要记录修改
+	changed    *SessionData // 后端修改过的数据
+}
+
+// SessionData 在服务器之间传递的session数据
+// Data中值为nil表示删除该key
+type SessionData struct {
+	FrontendId string            `json:"frontendId"`
+	Cid        string            `json:"cid"`
+	Uid        string            `json:"uid"`
+	Serializer string            `json:"serializer,omitempty"`
+	Servers    map[string]string `json:"servers,omitempty"`
+	Data       map[string]any    `json:"data,omitempty"`
+}
+
+func NewSession(frontendId, cid string) *Session {
+	return &Session{
+		frontendId: frontendId,
+		cid:        cid,
+		servers:    make(map[string]string),
+		data:       make(map[string]any),
+	}
+}
+
+// NewSessionFromData 后端服务器根据转发过来的数据还原session
+func NewSessionFromData(d *SessionData) *Session {
+	s := NewSession(d.FrontendId, d.Cid)
+	s.uid = d.Uid
+	s.serializer = d.Serializer
+	s.track = true
+	for k, v := range d.Servers {
+		s.servers[k] = v
+	}
+	for k, v := range d.Data {
+		s.data[k] = v
+	}
+	return s
+}
+
+func (s *Session) GetFrontendId() string {
+	return s.frontendId
+}
+
+func (s *Session) GetCid() string {
+	return s.cid
+}
+
+func (s *Session) GetUid() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.uid
+}
+
+func (s *Session) SetUid(uid string) {
+	s.Lock()
+	defer s.Unlock()
+	s.uid = uid
+}
+
+// Serializer 客户端消息体的序列化方式 未协商或者不认识时使用json
+func (s *Session) Serializer() Serializer {
+	s.RLock()
+	name := s.serializer
+	s.RUnlock()
+	if serializer, ok := GetSerializer(name); ok {
+		return serializer
+	}
+	return JSONSerializer{}
+}
+
+func (s *Session) SetSerializer(name string) {
+	s.Lock()
+	defer s.Unlock()
+	s.serializer = name
+}
+
+func (s *Session) Compress() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.compress
+}
+
+func (s *Session) SetCompress(compress bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.compress = compress
+}
+
+// GetServer 用户绑定的某类型服务器id
+func (s *Session) GetServer(serverType string) string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.servers[serverType]
+}
+
+// BindServer 绑定后该类型的请求都转发到这台服务器
+func (s *Session) BindServer(serverType, serverId string) {
+	s.Lock()
+	defer s.Unlock()
+	s.servers[serverType] = serverId
+	s.change().Servers[serverType] = serverId
+}
+
+func (s *Session) Get(key string) (any, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *Session) Set(key string, value any) {
+	s.Lock()
+	defer s.Unlock()
+	s.data[key] = value
+	s.change().Data[key] = value
+}
+
+func (s *Session) Remove(key string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.data, key)
+	s.change().Data[key] = nil
+}
+
+// Snapshot 转发请求时带给后端的完整数据
+func (s *Session) Snapshot() *SessionData {
+	s.RLock()
+	defer s.RUnlock()
+	d := &SessionData{
+		FrontendId: s.frontendId,
+		Cid:        s.cid,
+		Uid:        s.uid,
+		Serializer: s.serializer,
+		Servers:    make(map[string]string, len(s.servers)),
+		Data:       make(map[string]any, len(s.data)),
+	}
+	for k, v := range s.servers {
+		d.Servers[k] = v
+	}
+	for k, v := range s.data {
+		d.Data[k] = v
+	}
+	return d
+}
+
+// Changes 取出自上次调用以来修改过的数据 没有修改时返回nil
+func (s *Session) Changes() *SessionData {
+	s.Lock()
+	defer s.Unlock()
+	d := s.changed
+	s.changed = nil
+	return d
+}
+
+// Apply 合并后端推回的修改 cid和uid由connector维护 不会被覆盖
+func (s *Session) Apply(d *SessionData) {
+	if d == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for k, v := range d.Servers {
+		if v == "" {
+			delete(s.servers, k)
+		} else {
+			s.servers[k] = v
+		}
+	}
+	for k, v := range d.Data {
+		if v == nil {
+			delete(s.data, k)
+		} else {
+			s.data[k] = v
+		}
+	}
+}
+
+// change connector上的session不需要记录修改 返回的数据直接丢弃
+func (s *Session) change() *SessionData {
+	if !s.track {
+		return &SessionData{
+			Servers: make(map[string]string),
+			Data:    make(map[string]any),
+		}
+	}
+	if s.changed == nil {
+		s.changed = &SessionData{
+			FrontendId: s.frontendId,
+			Cid:        s.cid,
+			Uid:        s.uid,
+			Servers:    make(map[string]string),
+			Data:       make(map[string]any),
+		}
+	}
+	return s.changed
+}
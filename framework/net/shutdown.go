@@ -0,0 +1,188 @@
+package net
+
+import (
+	"common/biz"
+	"common/logs"
+	"context"
+	"time"
+)
+
+// DefaultShutdownTimeout 停机时等待转发中的请求响应的最长时间
+const DefaultShutdownTimeout = 10 * time.Second
+
+// DefaultRequestTimeout 转发给后端的request等待响应的默认时间
+const DefaultRequestTimeout = 10 * time.Second
+
+// shutdownKickTimeout 踢下线包写出的时间 不占用等待请求的时间 请求一直没有响应时客户端也能收到踢下线
+const shutdownKickTimeout = time.Second
+
+// shutdownPollInterval 停机时检查请求和连接是否处理完的间隔
+const shutdownPollInterval = 100 * time.Millisecond
+
+// Shutdown 停止接收新连接和新请求 最多等待timeout让转发中的请求响应 然后踢掉所有连接
+// 踢下线包写出后仍未断开的连接直接关闭
+func (m *Manager) Shutdown(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	m.draining.Store(true)
+	m.RLock()
+	server, certs := m.server, m.certs
+	m.RUnlock()
+	if server != nil {
+		// websocket连接已经被hijack 不受影响 这里只关闭listener
+		if err := server.Shutdown(ctx); err != nil {
+			logs.Error("connector shutdown listener err:%v", err)
+		}
+	}
+	if certs != nil {
+		if err := certs.Close(); err != nil {
+			logs.Error("connector close tls cert watcher err:%v", err)
+		}
+	}
+	if !m.waitUntil(ctx, func() bool { return m.pendingCount() == 0 }) {
+		logs.Warn("connector shutdown with %d requests not responded", m.pendingCount())
+	}
+	for _, c := range m.allClients() {
+		m.Kick(c, KickReasonServerRestart)
+	}
+	kickCtx, kickCancel := context.WithTimeout(context.Background(), shutdownKickTimeout)
+	defer kickCancel()
+	if !m.waitUntil(kickCtx, func() bool { return len(m.allClients()) == 0 }) {
+		for _, c := range m.allClients() {
+			m.closeClient(c)
+		}
+	}
+	logs.Info("connector websocket shutdown finish")
+}
+
+// waitUntil 等待条件满足 超时返回false
+func (m *Manager) waitUntil(ctx context.Context, done func() bool) bool {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// pendingRequest 转发给后端还没有响应的request
+type pendingRequest struct {
+	route string
+	timer *time.Timer
+}
+
+// addPending 转发给后端之前调用 后端的响应可能比转发返回更早到达
+func (m *Manager) addPending(cid string, msg *Message) {
+	m.Lock()
+	defer m.Unlock()
+	requests, ok := m.pending[cid]
+	if !ok {
+		requests = make(map[uint]*pendingRequest)
+		m.pending[cid] = requests
+	}
+	if old, ok := requests[msg.ID]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	requests[msg.ID] = &pendingRequest{route: msg.Route}
+}
+
+// expirePendingAfter 转发成功后开始计时 超过timeout没有响应时给客户端返回超时错误
+func (m *Manager) expirePendingAfter(cid string, id uint, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = m.RequestTimeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	m.Lock()
+	defer m.Unlock()
+	p, ok := m.pending[cid][id]
+	if !ok {
+		// 已经收到响应
+		return
+	}
+	// 持有锁时设置timer expirePending需要先拿到锁 不会读到未设置的timer
+	p.timer = time.AfterFunc(timeout, func() {
+		if !m.expirePending(cid, id, p) {
+			return
+		}
+		logs.Warn("client[%s] request id:%d route:%s not responded in %v", cid, id, p.route, timeout)
+		if c, ok := m.getClient(cid); ok {
+			_ = m.responseError(c, &Message{Type: Request, ID: id, Route: p.route}, biz.RequestTimeout)
+		}
+	})
+}
+
+// donePending 收到响应或者转发失败 返回false表示已经超时或者连接已关闭
+func (m *Manager) donePending(cid string, id uint) bool {
+	m.Lock()
+	defer m.Unlock()
+	requests, ok := m.pending[cid]
+	if !ok {
+		return false
+	}
+	p, ok := requests[id]
+	if !ok {
+		return false
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	delete(requests, id)
+	if len(requests) == 0 {
+		delete(m.pending, cid)
+	}
+	return true
+}
+
+// expirePending 只删除p对应的记录 同一id重新转发后旧的timer不影响新记录
+func (m *Manager) expirePending(cid string, id uint, p *pendingRequest) bool {
+	m.Lock()
+	defer m.Unlock()
+	requests, ok := m.pending[cid]
+	if !ok || requests[id] != p {
+		return false
+	}
+	delete(requests, id)
+	if len(requests) == 0 {
+		delete(m.pending, cid)
+	}
+	return true
+}
+
+// removePending 连接关闭时调用 需要持有锁
+func (m *Manager) removePending(cid string) {
+	for _, p := range m.pending[cid] {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}
+	delete(m.pending, cid)
+}
+
+func (m *Manager) pendingCount() int {
+	m.RLock()
+	defer m.RUnlock()
+	count := 0
+	for _, v := range m.pending {
+		count += len(v)
+	}
+	return count
+}
+
+func (m *Manager) allClients() []Connection {
+	m.RLock()
+	defer m.RUnlock()
+	clients := make([]Connection, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
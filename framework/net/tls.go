@@ -0,0 +1,57 @@
+package net
+
+import (
+	"common/logs"
+	"crypto/tls"
+	"framework/filewatch"
+	"sync/atomic"
+)
+
+// certReloader 握手时读取当前证书 证书文件变化后重新加载 已建立的连接不受影响
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	watcher  *filewatch.Watcher
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	// 兼容k8s Secret挂载 证书是指向..data的符号链接
+	watcher, err := filewatch.Watch([]string{certFile, keyFile}, func([]string) {
+		// 证书和私钥可能没有同时写完 加载失败时继续使用旧证书 等下一次变化
+		if err := r.load(); err != nil {
+			logs.Warn("reload tls cert err:%v", err)
+			return
+		}
+		logs.Info("reload tls cert %s", r.certFile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.watcher = watcher
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+func (r *certReloader) Close() error {
+	return r.watcher.Close()
+}
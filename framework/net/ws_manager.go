@@ -1,12 +1,559 @@
 package net
 
+import (
+	"common/biz"
+	"common/logs"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"framework/waError"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	websocketUpgrade = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+)
+
+type CheckOriginHandler func(r *http.Request) bool
+
+// EventHandler 按包类型处理客户端数据 返回error时断开连接
+type EventHandler func(packet *Packet, c Connection) error
+
+// RemoteHandler 路由不属于本服务器时交给它转发给后端
+// 转发成功时返回等待后端响应的时间 0表示使用Manager.RequestTimeout
+type RemoteHandler func(session *Session, msg *Message, route *Route) (time.Duration, *waError.Error)
+
+// BindUserHandler 连接entry成功绑定uid后调用 seq是这次entry的序号
+type BindUserHandler func(session *Session, seq uint64)
+
+// MsgPack 客户端发来的一帧数据
+type MsgPack struct {
+	Cid  string
+	Body []byte
+}
+
+// user 已entry的连接
+type user struct {
+	conn Connection
+	seq  uint64
+}
+
 type Manager struct {
+	sync.RWMutex
+	ServerId           string
+	ServerType         string
+	CheckOriginHandler CheckOriginHandler
+	websocketUpgrade   *websocket.Upgrader
+	server             *http.Server
+	clients            map[string]Connection
+	users              map[string]*user // uid -> 已entry的连接
+	// entrySeq 最近一次entry的序号 见nextEntrySeq
+	entrySeq       uint64
+	ClientReadChan chan *MsgPack
+	handlers       map[PackageType]EventHandler
+	RouteDict      *RouteDict
+	// HeartTime 心跳间隔 握手时下发给客户端 超过两个间隔没有收到数据的连接会被断开
+	HeartTime time.Duration
+	// Handlers 本服务器类型的路由处理函数
+	Handlers *HandlerRegistry
+	// RemoteHandler 其他服务器类型的路由
+	RemoteHandler RemoteHandler
+	// BindUserHandler 用于通知其他connector踢掉同一uid的旧连接
+	BindUserHandler BindUserHandler
+	// WriteQueueSize 每个连接的写队列长度
+	WriteQueueSize int
+	// SlowConsumer 写队列满时推送的处理方式 默认丢弃
+	SlowConsumer SlowConsumerPolicy
+	// RateLimit 请求限流 nil表示不限制
+	RateLimit *RateLimitConfig
+	limiters  map[string]*limiter
+	// authTimers cid -> 未entry连接的踢下线定时器
+	authTimers map[string]*time.Timer
+	// pending cid -> msgId -> 超时定时器 转发给后端还没有响应的request 停机时等待
+	pending  map[string]map[uint]*pendingRequest
+	draining atomic.Bool
+	// RequestTimeout 转发给后端的request等待响应的最长时间 默认DefaultRequestTimeout
+	RequestTimeout time.Duration
+	// CompressThreshold 协商了压缩的连接 消息体达到这个字节数时gzip压缩 0表示不压缩
+	CompressThreshold int
+	// CertFile KeyFile 都配置时使用wss 证书文件变化后自动重新加载
+	CertFile string
+	KeyFile  string
+	// ClientConfig 握手时下发给客户端的配置 每次握手调用 配置重新加载后立即生效
+	ClientConfig func() json.RawMessage
+	certs        *certReloader
+	// AuthTimeout 未entry的连接只允许握手和心跳 超时踢下线
+	AuthTimeout time.Duration
 }
 
 func (m *Manager) Run(addr string) {
+	if m.CheckOriginHandler != nil {
+		m.websocketUpgrade.CheckOrigin = m.CheckOriginHandler
+	}
+	go m.clientReadChanHandler()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", m.serveWS)
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+	var certs *certReloader
+	if m.CertFile != "" && m.KeyFile != "" {
+		var err error
+		if certs, err = newCertReloader(m.CertFile, m.KeyFile); err != nil {
+			logs.Fatal("connector load tls cert err:%v", err)
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+	m.Lock()
+	m.server = server
+	m.certs = certs
+	m.Unlock()
+	if m.draining.Load() {
+		return
+	}
+	var err error
+	if certs != nil {
+		logs.Info("connector websocket listen on %s with tls", addr)
+		// 证书由TLSConfig.GetCertificate提供
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		logs.Info("connector websocket listen on %s", addr)
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logs.Fatal("connector listen serve err:%v", err)
+	}
+}
+
+func (m *Manager) serveWS(w http.ResponseWriter, r *http.Request) {
+	if m.draining.Load() {
+		http.Error(w, "server restarting", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := m.websocketUpgrade.Upgrade(w, r, nil)
+	if err != nil {
+		logs.Error("websocket upgrade err:%v", err)
+		return
+	}
+	client := NewWsConnection(conn, m)
+	m.addClient(client)
+	client.Run()
+	m.checkAuth(client)
+}
+
+func (m *Manager) addClient(client *WsConnection) {
+	m.Lock()
+	defer m.Unlock()
+	m.clients[client.Cid] = client
+	if m.RateLimit != nil {
+		m.limiters[client.Cid] = newLimiter(m.RateLimit)
+	}
+	connections.Add(1)
+}
+
+// removeClient 读写任一goroutine退出都会调用 连接只会被移除和关闭一次
+// 连接上的session随连接一起移除
+func (m *Manager) removeClient(client *WsConnection) {
+	m.Lock()
+	c, ok := m.clients[client.Cid]
+	if ok && c == client {
+		delete(m.clients, client.Cid)
+		connections.Add(-1)
+	}
+	if u, ok := m.users[client.GetUid()]; ok && u.conn == client {
+		delete(m.users, client.GetUid())
+		sessions.Add(-1)
+	}
+	delete(m.limiters, client.Cid)
+	m.removePending(client.Cid)
+	m.stopAuth(client.Cid)
+	m.Unlock()
+	client.Close()
+}
+
+func (m *Manager) closeClient(client Connection) {
+	if c, ok := client.(*WsConnection); ok {
+		m.removeClient(c)
+		return
+	}
+	client.Close()
+}
+
+// bindUser entry成功后建立uid索引 同一uid已有的连接会被踢下线
+func (m *Manager) bindUser(c Connection) {
+	uid := c.GetUid()
+	m.Lock()
+	if _, ok := m.clients[c.GetCid()]; !ok {
+		// entry过程中连接已经关闭
+		m.Unlock()
+		return
+	}
+	m.stopAuth(c.GetCid())
+	old, ok := m.users[uid]
+	seq := m.nextEntrySeq()
+	m.users[uid] = &user{conn: c, seq: seq}
+	if !ok {
+		sessions.Add(1)
+	}
+	m.Unlock()
+	if ok && old.conn != c {
+		logs.Info("user[%s] login again, kick client[%s]", uid, old.conn.GetCid())
+		m.Kick(old.conn, KickReasonDuplicateLogin)
+	}
+	if m.BindUserHandler != nil {
+		m.BindUserHandler(c.GetSession(), seq)
+	}
+}
+
+// nextEntrySeq 混合逻辑时钟 取本机时间和已知最大序号+1中较大的 需要持有锁
+// 收到其他connector的序号后 本机之后的entry序号一定更大 不依赖各机器的时钟一致
+func (m *Manager) nextEntrySeq() uint64 {
+	if now := uint64(time.Now().UnixNano()); now > m.entrySeq {
+		m.entrySeq = now
+	} else {
+		m.entrySeq++
+	}
+	return m.entrySeq
+}
+
+// KickUser 其他connector(serverId为src)上的uid以序号seq entry 踢掉本机更早entry的连接
+// 两边同时entry时按序号和serverId比较 两边的结果一致 只保留一个连接
+func (m *Manager) KickUser(uid, reason, src string, seq uint64) bool {
+	m.Lock()
+	if seq > m.entrySeq {
+		m.entrySeq = seq
+	}
+	u, ok := m.users[uid]
+	newer := ok && (u.seq > seq || (u.seq == seq && m.ServerId > src))
+	m.Unlock()
+	if !ok || newer {
+		return false
+	}
+	logs.Info("user[%s] kicked, client[%s] reason:%s", uid, u.conn.GetCid(), reason)
+	m.Kick(u.conn, reason)
+	return true
+}
+
+func (m *Manager) getUser(uid string) (Connection, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	u, ok := m.users[uid]
+	if !ok {
+		return nil, false
+	}
+	return u.conn, true
+}
+
+func (m *Manager) getClient(cid string) (Connection, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	c, ok := m.clients[cid]
+	return c, ok
+}
+
+// UpdateSession 合并后端推回的session修改 连接已关闭时session随之销毁 直接忽略
+func (m *Manager) UpdateSession(d *SessionData) bool {
+	if d == nil {
+		return false
+	}
+	c, ok := m.getClient(d.Cid)
+	if !ok {
+		return false
+	}
+	c.GetSession().Apply(d)
+	return true
+}
+
+func (m *Manager) clientReadChanHandler() {
+	for body := range m.ClientReadChan {
+		m.decodeClientPack(body)
+	}
+}
+
+// decodeClientPack 拆包后按包类型分发 非法数据直接断开连接
+func (m *Manager) decodeClientPack(body *MsgPack) {
+	conn, ok := m.getClient(body.Cid)
+	if !ok {
+		return
+	}
+	packets, err := Decode(body.Body)
+	if err != nil {
+		logs.Error("client[%s] decode packet err:%v", body.Cid, err)
+		m.closeClient(conn)
+		return
+	}
+	for _, packet := range packets {
+		if err := m.routeEvent(packet, conn); err != nil {
+			logs.Error("client[%s] handle packet type:%d err:%v", body.Cid, packet.Type, err)
+			m.closeClient(conn)
+			return
+		}
+	}
+}
+
+func (m *Manager) routeEvent(packet *Packet, c Connection) error {
+	handler, ok := m.handlers[packet.Type]
+	if !ok {
+		return ErrWrongPacketType
+	}
+	return handler(packet, c)
+}
+
+func (m *Manager) setupEventHandlers() {
+	m.handlers[Handshake] = m.HandshakeHandler
+	m.handlers[HandshakeAck] = m.HandshakeAckHandler
+	m.handlers[Heartbeat] = m.HeartbeatHandler
+	m.handlers[Data] = m.MessageHandler
+	m.handlers[Kick] = m.KickHandler
+}
+
+// HandshakeHandler 回应握手参数
+func (m *Manager) HandshakeHandler(packet *Packet, c Connection) error {
+	var req HandshakeRequest
+	if len(packet.Body) > 0 {
+		if err := json.Unmarshal(packet.Body, &req); err != nil {
+			return err
+		}
+	}
+	serializer, ok := GetSerializer(req.Sys.Serializer)
+	if !ok {
+		// 回应失败后断开连接
+		logs.Warn("client[%s] handshake with unsupported serializer:%s", c.GetCid(), req.Sys.Serializer)
+		data, _ := json.Marshal(&HandshakeResponse{Code: handshakeFail})
+		buf, err := Encode(Handshake, data)
+		if err != nil {
+			return err
+		}
+		c.Kick(buf)
+		return nil
+	}
+	c.GetSession().SetSerializer(serializer.Name())
+	compress := req.Sys.Compress && m.CompressThreshold > 0
+	c.GetSession().SetCompress(compress)
+	res := HandshakeResponse{
+		Code: handshakeOk,
+		Sys: HandshakeResponseSys{
+			Heartbeat:  int(m.HeartTime / time.Second),
+			Dict:       m.RouteDict.Dict(),
+			Serializer: serializer.Name(),
+			Compress:   compress,
+		},
+	}
+	if m.ClientConfig != nil {
+		res.Sys.Config = m.ClientConfig()
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return m.sendPacket(c, Handshake, data)
+}
+
+func (m *Manager) HandshakeAckHandler(packet *Packet, c Connection) error {
+	return nil
+}
+
+// HeartbeatHandler 收到心跳原样回应
+func (m *Manager) HeartbeatHandler(packet *Packet, c Connection) error {
+	return m.sendPacket(c, Heartbeat, nil)
+}
+
+// MessageHandler 解析数据包中的消息
+func (m *Manager) MessageHandler(packet *Packet, c Connection) error {
+	msg, err := MessageDecode(packet.Body, m.RouteDict)
+	if err != nil {
+		return err
+	}
+	if msg.Type != Request && msg.Type != Notify {
+		return ErrWrongMessageType
+	}
+	if msg.Compressed && !c.GetSession().Compress() {
+		return ErrInvalidMessage
+	}
+	if m.draining.Load() {
+		return m.responseError(c, msg, biz.ServerMaintenance)
+	}
+	if !m.allow(c, msg.Route) {
+		return m.responseError(c, msg, biz.RequestTooFrequent)
+	}
+	// 限流之后再解压 被限流的请求不占用分发goroutine解压
+	if msg.Compressed {
+		if msg.Data, err = Decompress(msg.Data); err != nil {
+			return err
+		}
+		msg.Compressed = false
+	}
+	if msg.Route != EntryRoute && c.GetUid() == "" {
+		logs.Warn("client[%s] request route:%s before entry", c.GetCid(), msg.Route)
+		return m.responseError(c, msg, biz.TokenInfoError)
+	}
+	// 一个连接只能entry一次 否则旧uid的索引不会被清理
+	if msg.Route == EntryRoute && c.GetUid() != "" {
+		logs.Warn("client[%s] uid:%s entry again", c.GetCid(), c.GetUid())
+		return m.responseError(c, msg, biz.RequestDataError)
+	}
+	route, err := ParseRoute(msg.Route)
+	if err != nil {
+		logs.Warn("client[%s] parse route err:%v", c.GetCid(), err)
+		return m.responseError(c, msg, biz.RouteNotFound)
+	}
+	if route.ServerType != m.ServerType {
+		if m.RemoteHandler == nil {
+			logs.Warn("client[%s] route:%s not handled", c.GetCid(), msg.Route)
+			return m.responseError(c, msg, biz.RouteNotFound)
+		}
+		// 先记录再转发 后端响应可能比RemoteHandler返回更早到达
+		if msg.Type == Request {
+			m.addPending(c.GetCid(), msg)
+		}
+		timeout, bizErr := m.RemoteHandler(c.GetSession(), msg, route)
+		if bizErr != nil {
+			if msg.Type == Request {
+				m.donePending(c.GetCid(), msg.ID)
+			}
+			return m.responseError(c, msg, bizErr)
+		}
+		// 后端的路由只有配置在字典中的才按路由统计 其他合法格式的路由也会被转发 计入otherRoute
+		if _, ok := m.RouteDict.Code(msg.Route); ok {
+			messagesIn.Add(metricRoute(msg.Route), 1)
+		} else {
+			messagesIn.Add(otherRoute, 1)
+		}
+		if msg.Type == Request {
+			m.expirePendingAfter(c.GetCid(), msg.ID, timeout)
+		}
+		return nil
+	}
+	// 认证之后并且路由已注册才按路由统计 客户端不能用乱发的路由占满统计的路由数
+	if _, ok := m.Handlers.Get(msg.Route); ok {
+		messagesIn.Add(metricRoute(msg.Route), 1)
+	}
+	data, bizErr := m.Handlers.Dispatch(c.GetSession(), msg)
+	if bizErr != nil {
+		return m.responseError(c, msg, bizErr)
+	}
+	if msg.Route == EntryRoute && c.GetUid() != "" {
+		m.bindUser(c)
+	}
+	return m.responseData(c, msg, data)
+}
+
+// allow 请求限流 超限次数过多的连接踢下线
+func (m *Manager) allow(c Connection, route string) bool {
+	if m.RateLimit == nil {
+		return true
+	}
+	m.RLock()
+	l, ok := m.limiters[c.GetCid()]
+	m.RUnlock()
+	if !ok {
+		// 限流器随连接创建 找不到说明连接已经关闭
+		return false
+	}
+	if l.allow(route) {
+		return true
+	}
+	if l.violate() {
+		logs.Warn("client[%s] uid:%s request too frequent, kick", c.GetCid(), c.GetUid())
+		m.Kick(c, KickReasonRateLimit)
+	}
+	return false
+}
+
+// RegisterHandler 注册本服务器的路由 路由同时加入字典 客户端可以用编码代替路由
+func (m *Manager) RegisterHandler(route string, handler HandlerFunc) error {
+	if err := m.RouteDict.Add(route); err != nil {
+		return err
+	}
+	return m.Handlers.Register(route, handler)
+}
+
+// KickHandler 只能由服务端发出
+func (m *Manager) KickHandler(packet *Packet, c Connection) error {
+	return ErrWrongPacketType
+}
+
+// Response 把后端的响应发给客户端 连接已关闭时返回ErrConnectionClosed
+// 请求已经超时时客户端已经收到超时错误 丢弃响应并返回ErrRequestExpired
+// route是请求的路由 只用于统计
+func (m *Manager) Response(cid, route string, id uint, data []byte) error {
+	if !m.donePending(cid, id) {
+		if _, ok := m.getClient(cid); !ok {
+			return ErrConnectionClosed
+		}
+		return ErrRequestExpired
+	}
+	c, ok := m.getClient(cid)
+	if !ok {
+		return ErrConnectionClosed
+	}
+	messagesOut.Add(knownMetricRoute(route), 1)
+	return m.sendMessage(c, &Message{
+		Type: Response,
+		ID:   id,
+		Data: data,
+	})
+}
+
+// Push 服务端主动推送给客户端
+func (m *Manager) Push(cid, route string, data []byte) error {
+	c, ok := m.getClient(cid)
+	if !ok {
+		return ErrConnectionClosed
+	}
+	buf, err := m.encodePush(route, data, c.GetSession().Compress())
+	if err != nil {
+		return err
+	}
+	messagesOut.Add(metricRoute(route), 1)
+	return c.SendPush(buf)
+}
+
+func (m *Manager) sendMessage(c Connection, msg *Message) error {
+	m.compressMessage(msg, c.GetSession().Compress())
+	body, err := MessageEncode(msg, m.RouteDict)
+	if err != nil {
+		return err
+	}
+	return m.sendPacket(c, Data, body)
+}
 
+func (m *Manager) sendPacket(c Connection, t PackageType, body []byte) error {
+	buf, err := Encode(t, body)
+	if err != nil {
+		return err
+	}
+	return c.SendMessage(buf)
 }
 
 func NewManager() *Manager {
-	return &Manager{}
+	upgrade := websocketUpgrade
+	m := &Manager{
+		websocketUpgrade: &upgrade,
+		clients:          make(map[string]Connection),
+		users:            make(map[string]*user),
+		limiters:         make(map[string]*limiter),
+		authTimers:       make(map[string]*time.Timer),
+		pending:          make(map[string]map[uint]*pendingRequest),
+		ClientReadChan:   make(chan *MsgPack, 1024),
+		handlers:         make(map[PackageType]EventHandler),
+		RouteDict:        NewRouteDict(),
+		Handlers:         NewHandlerRegistry(),
+	}
+	m.setupEventHandlers()
+	return m
 }
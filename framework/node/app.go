@@ -0,0 +1,183 @@
+package node
+
+import (
+	"common/logs"
+	"errors"
+	"fmt"
+	"framework/game"
+	"framework/net"
+	"framework/remote"
+	"time"
+)
+
+// defaultMaxRunRoutineNum 配置中没有maxRunRoutineNum时同时处理的请求数
+const defaultMaxRunRoutineNum = 1024
+
+// App 后端服务器(hall/game) 通过nats接收connector转发的请求
+type App struct {
+	serverId     string
+	remoteCli    remote.Client
+	readChan     chan []byte
+	handlers     *net.HandlerRegistry
+	routineLimit chan struct{}
+	rpcTimeout   time.Duration
+}
+
+func Default() *App {
+	return &App{
+		readChan: make(chan []byte, 1024),
+		handlers: net.NewHandlerRegistry(),
+	}
+}
+
+// RegisterHandler 注册本服务器处理的路由 例如 hall.userHandler.updateInfo
+func (a *App) RegisterHandler(route string, handler net.HandlerFunc) {
+	if err := a.handlers.Register(route, handler); err != nil {
+		logs.Fatal("node register handler err:%v", err)
+	}
+}
+
+func (a *App) Run(serverId string) error {
+	conf := game.Current()
+	serverConf := conf.GetServer(serverId)
+	if serverConf == nil {
+		return fmt.Errorf("no server config found, serverId:%s", serverId)
+	}
+	return a.Serve(conf.ServersConf.Nats.Url, serverConf)
+}
+
+// Serve 连接nats并开始处理请求
+func (a *App) Serve(natsUrl string, serverConf *game.ServersConfig) error {
+	a.serverId = serverConf.ID
+	maxRunRoutineNum := serverConf.MaxRunRoutineNum
+	if maxRunRoutineNum <= 0 {
+		maxRunRoutineNum = defaultMaxRunRoutineNum
+	}
+	a.routineLimit = make(chan struct{}, maxRunRoutineNum)
+	a.rpcTimeout = time.Duration(serverConf.RPCTimeOut) * time.Second
+	if a.rpcTimeout <= 0 {
+		a.rpcTimeout = remote.DefaultPushTimeout
+	}
+	a.remoteCli = remote.NewNatsClient(natsUrl, a.serverId, a.readChan)
+	if err := a.remoteCli.Run(); err != nil {
+		return err
+	}
+	go a.readChanHandler()
+	logs.Info("%s server run success", a.serverId)
+	return nil
+}
+
+func (a *App) Close() {
+	if a.remoteCli != nil {
+		if err := a.remoteCli.Close(); err != nil {
+			logs.Error("%s close remote client err:%v", a.serverId, err)
+		}
+	}
+}
+
+// Push 推送给session所在connector上的客户端
+func (a *App) Push(session *net.Session, route string, data any) error {
+	body, err := session.Serializer().Marshal(data)
+	if err != nil {
+		return err
+	}
+	return a.send(&remote.Msg{
+		Type:  remote.PushMsg,
+		Dst:   session.GetFrontendId(),
+		Cid:   session.GetCid(),
+		Uid:   session.GetUid(),
+		Route: route,
+		Data:  body,
+	})
+}
+
+// PushSession 把session的修改同步回connector
+func (a *App) PushSession(session *net.Session) error {
+	changes := session.Changes()
+	if changes == nil {
+		return nil
+	}
+	return a.send(&remote.Msg{
+		Type:    remote.SessionMsg,
+		Dst:     session.GetFrontendId(),
+		Cid:     session.GetCid(),
+		Uid:     session.GetUid(),
+		Session: changes,
+	})
+}
+
+func (a *App) readChanHandler() {
+	for data := range a.readChan {
+		msg, err := remote.DecodeMsg(data)
+		if err != nil {
+			logs.Error("%s decode remote msg err:%v", a.serverId, err)
+			continue
+		}
+		if msg.Type != remote.RequestMsg {
+			logs.Warn("%s unexpected remote msg type:%d from %s", a.serverId, msg.Type, msg.Src)
+			continue
+		}
+		a.routineLimit <- struct{}{}
+		go func() {
+			defer func() {
+				<-a.routineLimit
+			}()
+			a.handle(msg)
+		}()
+	}
+}
+
+// handle 调用处理函数 request把结果和session修改一起响应给connector
+func (a *App) handle(msg *remote.Msg) {
+	if msg.Session == nil {
+		msg.Session = &net.SessionData{
+			FrontendId: msg.Src,
+			Cid:        msg.Cid,
+			Uid:        msg.Uid,
+		}
+	}
+	session := net.NewSessionFromData(msg.Session)
+	data, bizErr := a.handlers.Dispatch(session, &net.Message{
+		Type:  msg.MsgType,
+		ID:    msg.MsgId,
+		Route: msg.Route,
+		Data:  msg.Data,
+	})
+	if msg.MsgType != net.Request {
+		if err := a.PushSession(session); err != nil {
+			logs.Error("%s push session err:%v", a.serverId, err)
+		}
+		return
+	}
+	body, err := net.MarshalResult(session.Serializer(), data, bizErr)
+	if err != nil {
+		logs.Error("%s route:%s marshal response err:%v", a.serverId, msg.Route, err)
+		return
+	}
+	err = a.send(&remote.Msg{
+		Type:    remote.ResponseMsg,
+		Dst:     msg.Src,
+		Cid:     msg.Cid,
+		Uid:     msg.Uid,
+		MsgId:   msg.MsgId,
+		MsgType: msg.MsgType,
+		Route:   msg.Route,
+		Data:    body,
+		Session: session.Changes(),
+	})
+	if err != nil {
+		logs.Error("%s response route:%s err:%v", a.serverId, msg.Route, err)
+	}
+}
+
+func (a *App) send(msg *remote.Msg) error {
+	if msg.Dst == "" {
+		return errors.New("remote msg without dst")
+	}
+	msg.Src = a.serverId
+	data, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+	return a.remoteCli.SendMsg(msg.Dst, data)
+}
@@ -0,0 +1,121 @@
+package node
+
+import (
+	"common/config"
+	"common/logs"
+	"encoding/json"
+	"framework/game"
+	"framework/net"
+	"framework/remote"
+	"framework/waError"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+func TestAppHandleRequest(t *testing.T) {
+	config.Conf = &config.Config{}
+	logs.InitLog("test")
+	s, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		t.Fatalf("new nats server err:%v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	defer s.Shutdown()
+
+	app := Default()
+	app.RegisterHandler("hall.userHandler.updateInfo", func(session *net.Session, msg *net.Message) (any, *waError.Error) {
+		session.Set("nickname", string(msg.Data))
+		if err := app.Push(session, "hall.userHandler.onUpdate", map[string]string{"uid": session.GetUid()}); err != nil {
+			t.Errorf("push err:%v", err)
+		}
+		return map[string]string{"uid": session.GetUid()}, nil
+	})
+	if err := app.Serve(s.ClientURL(), &game.ServersConfig{ID: "hall-001", ServerType: "hall"}); err != nil {
+		t.Fatalf("serve err:%v", err)
+	}
+	defer app.Close()
+
+	connectorChan := make(chan []byte, 10)
+	connector := remote.NewNatsClient(s.ClientURL(), "connector001", connectorChan)
+	if err := connector.Run(); err != nil {
+		t.Fatalf("run connector client err:%v", err)
+	}
+	defer connector.Close()
+
+	req := &remote.Msg{
+		Type:    remote.RequestMsg,
+		Src:     "connector001",
+		Dst:     "hall-001",
+		Cid:     "connector001-10001",
+		Uid:     "10001",
+		MsgId:   1,
+		MsgType: net.Request,
+		Route:   "hall.userHandler.updateInfo",
+		Data:    []byte("wa"),
+		Session: &net.SessionData{FrontendId: "connector001", Cid: "connector001-10001", Uid: "10001"},
+	}
+	data, _ := req.Encode()
+	if err := connector.SendMsg("hall-001", data); err != nil {
+		t.Fatalf("send msg err:%v", err)
+	}
+
+	got := make(map[remote.MsgType]*remote.Msg)
+	for len(got) < 2 {
+		select {
+		case data := <-connectorChan:
+			msg, err := remote.DecodeMsg(data)
+			if err != nil {
+				t.Fatalf("decode msg err:%v", err)
+			}
+			got[msg.Type] = msg
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout, got %d msgs", len(got))
+		}
+	}
+
+	push := got[remote.PushMsg]
+	if push == nil || push.Cid != req.Cid || push.Route != "hall.userHandler.onUpdate" {
+		t.Fatalf("unexpected push %+v", push)
+	}
+	res := got[remote.ResponseMsg]
+	if res == nil || res.Cid != req.Cid || res.MsgId != req.MsgId || res.Src != "hall-001" {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	var result net.Result
+	if err := json.Unmarshal(res.Data, &result); err != nil {
+		t.Fatalf("unmarshal result err:%v", err)
+	}
+	if result.Code != 0 {
+		t.Fatalf("result code = %d, want 0", result.Code)
+	}
+	if res.Session == nil || res.Session.Data["nickname"] != "wa" {
+		t.Fatalf("session changes not returned, got %+v", res.Session)
+	}
+
+	req.Route = "hall.userHandler.notExist"
+	req.MsgId = 2
+	data, _ = req.Encode()
+	if err := connector.SendMsg("hall-001", data); err != nil {
+		t.Fatalf("send msg err:%v", err)
+	}
+	select {
+	case data := <-connectorChan:
+		msg, _ := remote.DecodeMsg(data)
+		_ = json.Unmarshal(msg.Data, &result)
+		if msg.Type != remote.ResponseMsg || result.Code == 0 {
+			t.Fatalf("unknown route should fail, got %+v %+v", msg, result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting unknown route response")
+	}
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"framework/game"
+	"framework/net"
+	"framework/remote"
+)
+
+// PushToUsers 推送给用户 不管用户在哪个connector上 返回不在线的uid
+// 在线但是payload不能按其序列化方式编码的用户不算不在线 通过*net.UndeliverableError返回
+func (a *App) PushToUsers(uids []string, route string, payload any) ([]string, error) {
+	data, err := net.MarshalPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	res := remote.Push(a.remoteCli, connectors(), &remote.PushRequest{
+		Uids:  uids,
+		Route: route,
+		Data:  data,
+	}, a.rpcTimeout)
+	return res.Offline(uids), res.Err(route)
+}
+
+// Broadcast 推送给所有connector上已entry的用户
+func (a *App) Broadcast(route string, payload any) error {
+	data, err := net.MarshalPayload(payload)
+	if err != nil {
+		return err
+	}
+	res := remote.Push(a.remoteCli, connectors(), &remote.PushRequest{
+		Route:     route,
+		Data:      data,
+		Broadcast: true,
+	}, a.rpcTimeout)
+	return res.Err(route)
+}
+
+func connectors() []string {
+	conf := game.Current()
+	ids := make([]string, 0, len(conf.ServersConf.Connector))
+	for _, v := range conf.ServersConf.Connector {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
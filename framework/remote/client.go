@@ -0,0 +1,17 @@
+package remote
+
+import "time"
+
+// RequestHandler 处理需要应答的请求 返回值作为应答
+type RequestHandler func(data []byte) []byte
+
+// Client 服务器之间的通信 每台服务器订阅以自己serverId命名的subject
+type Client interface {
+	Run() error
+	Close() error
+	SendMsg(dst string, data []byte) error
+	// Request 发送到subject并等待应答
+	Request(subject string, data []byte, timeout time.Duration) ([]byte, error)
+	// HandleRequest 订阅subject 收到请求后用handler的返回值应答
+	HandleRequest(subject string, handler RequestHandler) error
+}
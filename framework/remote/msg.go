@@ -0,0 +1,44 @@
+package remote
+
+import (
+	"encoding/json"
+	"framework/net"
+)
+
+// MsgType 服务器之间的消息类型
+type MsgType int
+
+const (
+	RequestMsg  MsgType = iota // connector转发客户端的request/notify给后端
+	ResponseMsg                // 后端响应request 带回session修改
+	PushMsg                    // 后端主动推送给客户端
+	SessionMsg                 // 后端推回session修改
+	KickMsg                    // 用户在其他connector上entry 踢掉Seq之前entry的连接
+)
+
+// Msg 在nats上传递的消息
+type Msg struct {
+	Type    MsgType          `json:"type"`
+	Src     string           `json:"src"` // 发送方serverId 响应和推送回到这里
+	Dst     string           `json:"dst"`
+	Cid     string           `json:"cid"`
+	Uid     string           `json:"uid"`
+	MsgId   uint             `json:"msgId"`
+	MsgType net.MessageType  `json:"msgType"` // request或者notify
+	Route   string           `json:"route"`
+	Data    []byte           `json:"data"`
+	Session *net.SessionData `json:"session,omitempty"`
+	Seq     uint64           `json:"seq,omitempty"` // KickMsg中entry的序号
+}
+
+func (m *Msg) Encode() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func DecodeMsg(data []byte) (*Msg, error) {
+	var m Msg
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"common/logs"
+	"github.com/nats-io/nats.go"
+	"time"
+)
+
+type NatsClient struct {
+	url      string
+	serverId string
+	conn     *nats.Conn
+	sub      *nats.Subscription
+	readChan chan []byte
+}
+
+// NewNatsClient 收到的消息放入readChan 由调用方消费
+func NewNatsClient(url, serverId string, readChan chan []byte) *NatsClient {
+	return &NatsClient{
+		url:      url,
+		serverId: serverId,
+		readChan: readChan,
+	}
+}
+
+func (c *NatsClient) Run() error {
+	var err error
+	c.conn, err = nats.Connect(c.url, nats.Name(c.serverId))
+	if err != nil {
+		logs.Error("connect nats server fail, err:%v", err)
+		return err
+	}
+	c.sub, err = c.conn.Subscribe(c.serverId, func(msg *nats.Msg) {
+		c.readChan <- msg.Data
+	})
+	if err != nil {
+		logs.Error("nats subscribe %s fail, err:%v", c.serverId, err)
+		c.conn.Close()
+		return err
+	}
+	// 确保订阅已经到达nats server 之后发给本服务器的消息不会丢
+	if err = c.conn.Flush(); err != nil {
+		logs.Error("nats flush subscription fail, err:%v", err)
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// Close 先处理完已收到的消息再断开
+func (c *NatsClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Drain()
+}
+
+func (c *NatsClient) SendMsg(dst string, data []byte) error {
+	if c.conn == nil {
+		return nats.ErrConnectionClosed
+	}
+	return c.conn.Publish(dst, data)
+}
+
+func (c *NatsClient) Request(subject string, data []byte, timeout time.Duration) ([]byte, error) {
+	if c.conn == nil {
+		return nil, nats.ErrConnectionClosed
+	}
+	msg, err := c.conn.Request(subject, data, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Data, nil
+}
+
+func (c *NatsClient) HandleRequest(subject string, handler RequestHandler) error {
+	if c.conn == nil {
+		return nats.ErrConnectionClosed
+	}
+	_, err := c.conn.Subscribe(subject, func(msg *nats.Msg) {
+		if err := msg.Respond(handler(msg.Data)); err != nil {
+			logs.Error("nats respond %s err:%v", subject, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return c.conn.Flush()
+}
@@ -0,0 +1,91 @@
+package remote
+
+import (
+	"common/config"
+	"common/logs"
+	"framework/net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// runNatsServer 启动进程内的nats server 随机端口
+func runNatsServer(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		t.Fatalf("new nats server err:%v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestNatsClientSendMsg(t *testing.T) {
+	config.Conf = &config.Config{}
+	logs.InitLog("test")
+	s := runNatsServer(t)
+
+	connectorChan := make(chan []byte, 1)
+	hallChan := make(chan []byte, 1)
+	connector := NewNatsClient(s.ClientURL(), "connector001", connectorChan)
+	hall := NewNatsClient(s.ClientURL(), "hall-001", hallChan)
+	for _, c := range []*NatsClient{connector, hall} {
+		if err := c.Run(); err != nil {
+			t.Fatalf("run nats client err:%v", err)
+		}
+		defer c.Close()
+	}
+
+	want := &Msg{
+		Type:    RequestMsg,
+		Src:     "connector001",
+		Dst:     "hall-001",
+		Cid:     "connector001-10001",
+		Uid:     "10001",
+		MsgId:   3,
+		MsgType: net.Request,
+		Route:   "hall.userHandler.updateInfo",
+		Data:    []byte(`{"nickname":"wa"}`),
+		Session: &net.SessionData{
+			FrontendId: "connector001",
+			Cid:        "connector001-10001",
+			Uid:        "10001",
+			Servers:    map[string]string{"hall": "hall-001"},
+		},
+	}
+	data, err := want.Encode()
+	if err != nil {
+		t.Fatalf("encode msg err:%v", err)
+	}
+	if err := connector.SendMsg(want.Dst, data); err != nil {
+		t.Fatalf("send msg err:%v", err)
+	}
+	select {
+	case data := <-hallChan:
+		got, err := DecodeMsg(data)
+		if err != nil {
+			t.Fatalf("decode msg err:%v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hall-001 did not receive msg")
+	}
+	select {
+	case data := <-connectorChan:
+		t.Fatalf("connector001 should not receive msg sent to hall-001, got %s", data)
+	default:
+	}
+}
@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"common/logs"
+	"encoding/json"
+	"framework/net"
+	"sync"
+	"time"
+)
+
+// DefaultPushTimeout 等待connector应答推送结果的时间
+const DefaultPushTimeout = 3 * time.Second
+
+// PushRequest 推送给connector上的用户 Broadcast为true时忽略Uids 推送给所有用户
+type PushRequest struct {
+	Uids      []string    `json:"uids"`
+	Route     string      `json:"route"`
+	Data      net.Payload `json:"data"` // 每种序列化方式编码后的数据
+	Broadcast bool        `json:"broadcast"`
+}
+
+// PushSubject connector接收推送请求的subject
+func PushSubject(connectorId string) string {
+	return connectorId + ".push"
+}
+
+// Push 把推送请求发给所有connector 合并各connector应答的推送结果
+// 没有应答的connector视为其上的用户都不在线
+func Push(cli Client, connectors []string, req *PushRequest, timeout time.Duration) *net.PushResult {
+	result := new(net.PushResult)
+	data, err := json.Marshal(req)
+	if err != nil {
+		logs.Error("marshal push request err:%v", err)
+		return result
+	}
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	for _, connectorId := range connectors {
+		wg.Add(1)
+		go func(connectorId string) {
+			defer wg.Done()
+			resData, err := cli.Request(PushSubject(connectorId), data, timeout)
+			if err != nil {
+				logs.Warn("push route:%s to %s err:%v", req.Route, connectorId, err)
+				return
+			}
+			var res net.PushResult
+			if err := json.Unmarshal(resData, &res); err != nil {
+				logs.Error("unmarshal push response from %s err:%v", connectorId, err)
+				return
+			}
+			lock.Lock()
+			result.Merge(&res)
+			lock.Unlock()
+		}(connectorId)
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"common"
+	"framework/game"
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigHandler struct {
+}
+
+func NewConfigHandler() *ConfigHandler {
+	return &ConfigHandler{}
+}
+
+// Config 返回gameConfig.json中backend不为true的配置项 客户端登录前就可以读取
+func (h *ConfigHandler) Config(ctx *gin.Context) {
+	common.Success(ctx, game.Current().ClientConfig())
+}
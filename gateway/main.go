@@ -7,16 +7,22 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"framework/game"
 	"gateway/app"
 	"os"
 )
 
-var configFile = flag.String("config", "application.yml", "config file")
+var (
+	configFile = flag.String("config", "application.yml", "config file")
+	gameConfig = flag.String("gameConfig", "../config", "game config dir")
+)
 
 func main() {
 	// 1. 加载配置
 	flag.Parse()
 	config.InitConfig(*configFile)
+	// gateway只下发客户端配置 不需要servers.json
+	game.InitGameConfig(*gameConfig)
 	fmt.Println(config.Conf)
 	// 2. 启动监控
 	go func() {
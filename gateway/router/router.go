@@ -21,5 +21,7 @@ func RegisterRouter() *gin.Engine {
 	r.Use(auth.Cors())
 	userHandler := api.NewUserHandler()
 	r.POST("/register", userHandler.Register)
+	configHandler := api.NewConfigHandler()
+	r.GET("/config", configHandler.Config)
 	return r
 }